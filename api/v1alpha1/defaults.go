@@ -0,0 +1,103 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package v1alpha1 // import "tideland.dev/codis/api/v1alpha1"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"sort"
+	"strings"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+// Defaults of the rule specification.
+const (
+	DefaultMode           = "both"
+	DefaultDeletionPolicy = "retain"
+	DefaultSelectorKey    = "rule"
+
+	DefaultServiceAccountName = "default"
+	DefaultEncryptionKey      = "public.pem"
+)
+
+//--------------------
+// DEFAULTS
+//--------------------
+
+// SetDefaults sets the unset fields of the rule specification to their
+// defaults and normalizes the namespace lists.
+func SetDefaults(rule *ConfigurationDistributionRule) {
+	if rule.Spec.Mode == "" {
+		rule.Spec.Mode = DefaultMode
+	}
+	if rule.Spec.DeletionPolicy == "" {
+		rule.Spec.DeletionPolicy = DefaultDeletionPolicy
+	}
+	if rule.Spec.SelectorKey == "" {
+		rule.Spec.SelectorKey = DefaultSelectorKey
+	}
+	if rule.Spec.ServiceAccountName == "" {
+		rule.Spec.ServiceAccountName = DefaultServiceAccountName
+	}
+	rule.Spec.SourceNamespaces = NormalizeNamespaces(rule.Spec.SourceNamespaces)
+	rule.Spec.Namespaces = NormalizeNamespaces(rule.Spec.Namespaces)
+	if rule.Spec.Encryption != nil {
+		if rule.Spec.Encryption.ConfigMapKey == "" {
+			rule.Spec.Encryption.ConfigMapKey = DefaultEncryptionKey
+		}
+		rule.Spec.Encryption.Namespaces = NormalizeNamespaces(rule.Spec.Encryption.Namespaces)
+	}
+	for i := range rule.Spec.Clusters {
+		rule.Spec.Clusters[i].Namespaces = NormalizeNamespaces(rule.Spec.Clusters[i].Namespaces)
+	}
+}
+
+// SourceNamespaces returns the namespaces the rule takes its sources
+// from. Without source namespaces it is the namespace of the rule.
+func SourceNamespaces(rule *ConfigurationDistributionRule) []string {
+	if len(rule.Spec.SourceNamespaces) == 0 {
+		return []string{rule.GetNamespace()}
+	}
+	return rule.Spec.SourceNamespaces
+}
+
+// ProjectedName returns the name of the copy of a projection. Without
+// a target name it is the name of the source.
+func ProjectedName(projection Projection) string {
+	if projection.TargetName != "" {
+		return projection.TargetName
+	}
+	return projection.Name
+}
+
+// NormalizeNamespaces returns the trimmed and lowercased namespaces
+// without empty entries and duplicates in sorted order.
+func NormalizeNamespaces(namespaces []string) []string {
+	if namespaces == nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, namespace := range namespaces {
+		namespace = strings.ToLower(strings.TrimSpace(namespace))
+		if namespace == "" || seen[namespace] {
+			continue
+		}
+		seen[namespace] = true
+		normalized = append(normalized, namespace)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// EOF
@@ -0,0 +1,124 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package v1alpha1 // import "tideland.dev/codis/api/v1alpha1"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"k8s.io/client-go/tools/cache"
+)
+
+//--------------------
+// INDEXERS
+//--------------------
+
+const (
+	// TargetNamespaceIndex is the name of the index of rules by their
+	// local target namespaces.
+	TargetNamespaceIndex = "targetNamespace"
+
+	// SelectorIndex is the name of the index of rules by their selected
+	// label in the form "key=value". Rules without selector are indexed
+	// with an empty value.
+	SelectorIndex = "selector"
+)
+
+// RuleIndexers returns the indexers to add to an informer of the generated
+// informer factory. The namespace index is already added by the informers.
+func RuleIndexers() cache.Indexers {
+	return cache.Indexers{
+		TargetNamespaceIndex: TargetNamespaceIndexFunc,
+		SelectorIndex:        SelectorIndexFunc,
+	}
+}
+
+// TargetNamespaceIndexFunc indexes a rule by its local target namespaces.
+func TargetNamespaceIndexFunc(obj interface{}) ([]string, error) {
+	rule, ok := obj.(*ConfigurationDistributionRule)
+	if !ok {
+		return nil, nil
+	}
+	return NormalizeNamespaces(rule.Spec.Namespaces), nil
+}
+
+// SelectorIndexFunc indexes a rule by its selected label.
+func SelectorIndexFunc(obj interface{}) ([]string, error) {
+	rule, ok := obj.(*ConfigurationDistributionRule)
+	if !ok {
+		return nil, nil
+	}
+	if rule.Spec.Selector == "" {
+		return []string{""}, nil
+	}
+	key := rule.Spec.SelectorKey
+	if key == "" {
+		key = DefaultSelectorKey
+	}
+	return []string{SelectorIndexValue(key, rule.Spec.Selector)}, nil
+}
+
+// SelectorIndexValue returns the value of a selected label in the
+// selector index.
+func SelectorIndexValue(key, value string) string {
+	return key + "=" + value
+}
+
+//--------------------
+// RULE INDEX
+//--------------------
+
+// RuleIndex looks up rules in the indexer of an informer with the
+// rule indexers.
+// +k8s:deepcopy-gen=false
+type RuleIndex struct {
+	indexer cache.Indexer
+}
+
+// NewRuleIndex creates a rule index based on the indexer.
+func NewRuleIndex(indexer cache.Indexer) *RuleIndex {
+	return &RuleIndex{
+		indexer: indexer,
+	}
+}
+
+// ByTargetNamespace returns the rules distributing into the local
+// target namespace.
+func (ri *RuleIndex) ByTargetNamespace(namespace string) ([]*ConfigurationDistributionRule, error) {
+	return ri.byIndex(TargetNamespaceIndex, namespace)
+}
+
+// BySelector returns the rules selecting the label. It also returns the
+// rules without selector as they select all sources.
+func (ri *RuleIndex) BySelector(key, value string) ([]*ConfigurationDistributionRule, error) {
+	list, err := ri.byIndex(SelectorIndex, SelectorIndexValue(key, value))
+	if err != nil {
+		return nil, err
+	}
+	all, err := ri.byIndex(SelectorIndex, "")
+	if err != nil {
+		return nil, err
+	}
+	return append(list, all...), nil
+}
+
+// byIndex returns the rules with the value in the index.
+func (ri *RuleIndex) byIndex(index, value string) ([]*ConfigurationDistributionRule, error) {
+	objs, err := ri.indexer.ByIndex(index, value)
+	if err != nil {
+		return nil, err
+	}
+	var list []*ConfigurationDistributionRule
+	for _, obj := range objs {
+		list = append(list, obj.(*ConfigurationDistributionRule))
+	}
+	return list, nil
+}
+
+// EOF
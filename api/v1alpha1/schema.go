@@ -37,6 +37,11 @@ var (
 	AddToScheme = SchemeBuilder.AddToScheme
 )
 
+// Resource takes an unqualified resource and returns a group qualified one.
+func Resource(resource string) schema.GroupResource {
+	return SchemeGroupVersion.WithResource(resource).GroupResource()
+}
+
 // addKnownTypes adds our new types.
 func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
@@ -53,43 +58,166 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 //--------------------
 
 // ConfigurationDistributionRuleSpec specifies one configuration distribution rule.
+// Sources are taken out of the source namespaces, by default the namespace
+// of the rule. Other source namespaces have to grant read access to the
+// service account of the rule. Beside the label selector sources can be
+// referenced explicitly by kind and name. With an aggregate all ConfigMaps
+// are merged into one copy instead of being copied one by one. Projections
+// copy keys of single sources into copies of the other kind. With an
+// encryption the data of Secret copies is encrypted per target namespace.
 type ConfigurationDistributionRuleSpec struct {
-	Mode       string   `json:"mode"`
-	Selector   string   `json:"selector"`
+	// +kubebuilder:validation:Enum=configmap;secret;both
+	// +optional
+	Mode               string   `json:"mode"`
+	SourceNamespaces   []string `json:"sourceNamespaces,omitempty"`
+	ServiceAccountName string   `json:"serviceAccountName,omitempty"`
+	SelectorKey        string   `json:"selectorKey,omitempty"`
+	// +optional
+	Selector string            `json:"selector"`
+	Sources  []SourceReference `json:"sources,omitempty"`
+	// +optional
+	Namespaces  []string          `json:"namespaces"`
+	Template    bool              `json:"template,omitempty"`
+	Parameters  map[string]string `json:"parameters,omitempty"`
+	Overrides   []Override        `json:"overrides,omitempty"`
+	SecretTypes map[string]string `json:"secretTypes,omitempty"`
+	Metadata    MetadataFilter    `json:"metadata,omitempty"`
+	Clusters    []Cluster         `json:"clusters,omitempty"`
+	Aggregate   *Aggregate        `json:"aggregate,omitempty"`
+	Projections []Projection      `json:"projections,omitempty"`
+	Encryption  *Encryption       `json:"encryption,omitempty"`
+	// +kubebuilder:validation:Enum=retain;delete
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+	DryRun         bool   `json:"dryRun,omitempty"`
+}
+
+// SourceReference references one source by its kind, ConfigMap or Secret,
+// and its name. Without a namespace it is the namespace of the rule.
+type SourceReference struct {
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Aggregate describes the ConfigMap the selected ConfigMaps are merged
+// into. Referenced sources take precedence in the order of their
+// definition, followed by the selected ones ordered by namespace and
+// name. The first source containing a key wins.
+type Aggregate struct {
+	Name string `json:"name"`
+}
+
+// Projection projects the keys of the selected source of the kind, ConfigMap
+// or Secret, with the name into a copy of the other kind, e.g. a CA bundle
+// stored as Secret into a ConfigMap. Without a target name the copy has
+// the name of the source.
+type Projection struct {
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind       string   `json:"kind"`
+	Name       string   `json:"name"`
+	Keys       []string `json:"keys"`
+	TargetName string   `json:"targetName,omitempty"`
+}
+
+// Encryption describes the encryption of the data of Secret copies with
+// the RSA public key stored in a ConfigMap in each encrypted target
+// namespace. Without namespaces all target namespaces are encrypted.
+type Encryption struct {
+	ConfigMapName string   `json:"configMapName"`
+	ConfigMapKey  string   `json:"configMapKey,omitempty"`
+	Namespaces    []string `json:"namespaces,omitempty"`
+}
+
+// Cluster describes a remote cluster copies are distributed to. Its kubeconfig
+// is stored with the given key in a Secret in the namespace of the rule.
+type Cluster struct {
+	Name       string `json:"name"`
+	SecretName string `json:"secretName"`
+	SecretKey  string `json:"secretKey,omitempty"`
+	// +optional
 	Namespaces []string `json:"namespaces"`
 }
 
-// ConfigurationDistributionRule contains the Kubernetes base informations and the spec.
-type ConfigurationDistributionRule struct {
-	metav1.TypeMeta   `json:",inline"`
-	metav1.ObjectMeta `json:"metadata,omitempty"`
+// ConfigurationDistributionRuleStatus contains the observed state of a rule.
+type ConfigurationDistributionRuleStatus struct {
+	// +optional
+	Ready             bool               `json:"ready"`
+	Clusters          []ClusterStatus    `json:"clusters,omitempty"`
+	PlannedOperations []PlannedOperation `json:"plannedOperations,omitempty"`
+	Conflicts         []Conflict         `json:"conflicts,omitempty"`
+}
 
-	Spec ConfigurationDistributionRuleSpec `json:"spec"`
+// Conflict describes a key of the aggregate with different values in
+// several sources. The value of the source is used, the ones of the
+// ignored sources are dropped.
+type Conflict struct {
+	Key    string `json:"key"`
+	Source string `json:"source"`
+	// +optional
+	Ignored []string `json:"ignored"`
 }
 
-// DeepCopyInto copies all properties of this object into another object of the
-// same type that is provided as a pointer.
-func (in *ConfigurationDistributionRule) DeepCopyInto(out *ConfigurationDistributionRule) {
-	out.TypeMeta = in.TypeMeta
-	out.ObjectMeta = in.ObjectMeta
-	out.Spec = ConfigurationDistributionRuleSpec{
-		Mode:       in.Spec.Mode,
-		Selector:   in.Spec.Selector,
-		Namespaces: make([]string, len(in.Spec.Namespaces)),
-	}
-	for i := range in.Spec.Namespaces {
-		out.Spec.Namespaces[i] = in.Spec.Namespaces[i]
-	}
+// PlannedOperation describes a write of a copy which would be performed
+// if the rule would not be in dry-run mode.
+type PlannedOperation struct {
+	Action    string `json:"action"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Cluster   string `json:"cluster,omitempty"`
 }
 
-// DeepCopyObject returns a generically typed copy of a rule.
-func (in *ConfigurationDistributionRule) DeepCopyObject() runtime.Object {
-	out := ConfigurationDistributionRule{}
-	in.DeepCopyInto(&out)
+// ClusterStatus contains the health of a remote cluster.
+type ClusterStatus struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
+}
+
+// MetadataFilter controls which labels and annotations of a source are
+// propagated to its copies. Entries are keys or prefixes ending with '*'.
+// Without allow entries all keys are allowed, deny entries always win.
+type MetadataFilter struct {
+	AllowLabels      []string `json:"allowLabels,omitempty"`
+	DenyLabels       []string `json:"denyLabels,omitempty"`
+	AllowAnnotations []string `json:"allowAnnotations,omitempty"`
+	DenyAnnotations  []string `json:"denyAnnotations,omitempty"`
+}
+
+// Override describes a patch of the distributed data for the target namespaces
+// matching the namespace name or the namespace label selector.
+type Override struct {
+	Namespace string            `json:"namespace,omitempty"`
+	Selector  map[string]string `json:"selector,omitempty"`
+	Set       map[string]string `json:"set,omitempty"`
+	Delete    []string          `json:"delete,omitempty"`
+	Merge     map[string]string `json:"merge,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=cdr;codisrule;rule
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name=Mode,type=string,JSONPath=.spec.mode
+// +kubebuilder:printcolumn:name=Targets,type=string,JSONPath=.spec.namespaces
+// +kubebuilder:printcolumn:name=Ready,type=boolean,JSONPath=.status.ready
+// +kubebuilder:printcolumn:name=Age,type=date,JSONPath=.metadata.creationTimestamp
+
+// ConfigurationDistributionRule contains the Kubernetes base informations and the spec.
+type ConfigurationDistributionRule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	return &out
+	Spec   ConfigurationDistributionRuleSpec   `json:"spec"`
+	Status ConfigurationDistributionRuleStatus `json:"status,omitempty"`
 }
 
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+
 // ConfigurationDistributionRuleList contains the Kubernetes base informations and a list of copiers.
 type ConfigurationDistributionRuleList struct {
 	metav1.TypeMeta `json:",inline"`
@@ -98,20 +226,4 @@ type ConfigurationDistributionRuleList struct {
 	Items []ConfigurationDistributionRule `json:"items"`
 }
 
-// DeepCopyObject returns a generically typed copy of a rule list.
-func (in *ConfigurationDistributionRuleList) DeepCopyObject() runtime.Object {
-	out := ConfigurationDistributionRuleList{}
-	out.TypeMeta = in.TypeMeta
-	out.ListMeta = in.ListMeta
-
-	if in.Items != nil {
-		out.Items = make([]ConfigurationDistributionRule, len(in.Items))
-		for i := range in.Items {
-			in.Items[i].DeepCopyInto(&out.Items[i])
-		}
-	}
-
-	return &out
-}
-
 // EOF
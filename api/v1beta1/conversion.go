@@ -0,0 +1,418 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package v1beta1 // import "tideland.dev/codis/api/v1beta1"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+// AnnotationSelectors is the annotation of v1alpha1 rules keeping the
+// v1beta1 label selectors which cannot be expressed in v1alpha1. This
+// way they survive the round trip through the v1alpha1 storage version.
+const AnnotationSelectors = "k8s.tideland.dev/v1beta1-selectors"
+
+// AnnotationSelectorKey is the annotation of v1beta1 rules keeping the
+// v1alpha1 selector key of rules without selector, which cannot be
+// expressed in v1beta1.
+const AnnotationSelectorKey = "k8s.tideland.dev/v1alpha1-selector-key"
+
+var (
+	// modesV1alpha1 maps the modes to their v1alpha1 values.
+	modesV1alpha1 = map[DistributionMode]string{
+		ModeConfigMap: "configmap",
+		ModeSecret:    "secret",
+		ModeBoth:      "both",
+	}
+
+	// deletionPoliciesV1alpha1 maps the deletion policies to their
+	// v1alpha1 values.
+	deletionPoliciesV1alpha1 = map[DeletionPolicy]string{
+		DeletionPolicyRetain: "retain",
+		DeletionPolicyDelete: "delete",
+	}
+)
+
+//--------------------
+// CONVERSION
+//--------------------
+
+// keptSelectors contains the label selectors lost in v1alpha1.
+type keptSelectors struct {
+	Source    *metav1.LabelSelector         `json:"source,omitempty"`
+	Overrides map[int]*metav1.LabelSelector `json:"overrides,omitempty"`
+}
+
+// ConvertToV1alpha1 converts a v1beta1 rule into a v1alpha1 rule. Label
+// selectors are reduced to their first match label and additionally
+// kept in an annotation if they cannot be expressed in v1alpha1. Without
+// selector the annotated selector key is restored.
+func ConvertToV1alpha1(in *ConfigurationDistributionRule) (*v1alpha1.ConfigurationDistributionRule, error) {
+	out := &v1alpha1.ConfigurationDistributionRule{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1alpha1.SchemeGroupVersion.String(),
+			Kind:       "ConfigurationDistributionRule",
+		},
+	}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	delete(out.Annotations, AnnotationSelectors)
+	delete(out.Annotations, AnnotationSelectorKey)
+	if len(out.Annotations) == 0 {
+		out.Annotations = nil
+	}
+	kept := keptSelectors{}
+	key, value, ok := selectorToV1alpha1(in.Spec.Source.Selector)
+	if !ok {
+		kept.Source = in.Spec.Source.Selector.DeepCopy()
+	}
+	if value == "" {
+		key = in.Annotations[AnnotationSelectorKey]
+	}
+	out.Spec = v1alpha1.ConfigurationDistributionRuleSpec{
+		Mode:               modeToV1alpha1(in.Spec.Mode),
+		SourceNamespaces:   copyStrings(in.Spec.Source.Namespaces),
+		ServiceAccountName: in.Spec.Source.ServiceAccountName,
+		SelectorKey:        key,
+		Selector:           value,
+		Namespaces:         copyStrings(in.Spec.Target.Namespaces),
+		Template:           in.Spec.Source.Template,
+		Parameters:         copyStringMap(in.Spec.Source.Parameters),
+		DeletionPolicy:     deletionPolicyToV1alpha1(in.Spec.DeletionPolicy),
+		DryRun:             in.Spec.DryRun,
+		Metadata: v1alpha1.MetadataFilter{
+			AllowLabels:      copyStrings(in.Spec.Source.Metadata.AllowLabels),
+			DenyLabels:       copyStrings(in.Spec.Source.Metadata.DenyLabels),
+			AllowAnnotations: copyStrings(in.Spec.Source.Metadata.AllowAnnotations),
+			DenyAnnotations:  copyStrings(in.Spec.Source.Metadata.DenyAnnotations),
+		},
+	}
+	if in.Spec.Target.Aggregate != nil {
+		out.Spec.Aggregate = &v1alpha1.Aggregate{Name: in.Spec.Target.Aggregate.Name}
+	}
+	if in.Spec.Target.Encryption != nil {
+		out.Spec.Encryption = &v1alpha1.Encryption{
+			ConfigMapName: in.Spec.Target.Encryption.ConfigMapName,
+			ConfigMapKey:  in.Spec.Target.Encryption.ConfigMapKey,
+			Namespaces:    copyStrings(in.Spec.Target.Encryption.Namespaces),
+		}
+	}
+	for _, projection := range in.Spec.Target.Projections {
+		out.Spec.Projections = append(out.Spec.Projections, v1alpha1.Projection{
+			Kind:       string(projection.Kind),
+			Name:       projection.Name,
+			Keys:       copyStrings(projection.Keys),
+			TargetName: projection.TargetName,
+		})
+	}
+	for _, ref := range in.Spec.Source.References {
+		out.Spec.Sources = append(out.Spec.Sources, v1alpha1.SourceReference{
+			Kind:      string(ref.Kind),
+			Name:      ref.Name,
+			Namespace: ref.Namespace,
+		})
+	}
+	if in.Spec.Source.SecretTypes != nil {
+		out.Spec.SecretTypes = make(map[string]string, len(in.Spec.Source.SecretTypes))
+		for from, to := range in.Spec.Source.SecretTypes {
+			out.Spec.SecretTypes[string(from)] = string(to)
+		}
+	}
+	for _, cluster := range in.Spec.Target.Clusters {
+		out.Spec.Clusters = append(out.Spec.Clusters, v1alpha1.Cluster{
+			Name:       cluster.Name,
+			SecretName: cluster.SecretName,
+			SecretKey:  cluster.SecretKey,
+			Namespaces: copyStrings(cluster.Namespaces),
+		})
+	}
+	for i, override := range in.Spec.Target.Overrides {
+		selector, ok := namespaceSelectorToV1alpha1(override.NamespaceSelector)
+		if !ok {
+			if kept.Overrides == nil {
+				kept.Overrides = map[int]*metav1.LabelSelector{}
+			}
+			kept.Overrides[i] = override.NamespaceSelector.DeepCopy()
+		}
+		out.Spec.Overrides = append(out.Spec.Overrides, v1alpha1.Override{
+			Namespace: override.Namespace,
+			Selector:  selector,
+			Set:       copyStringMap(override.Set),
+			Delete:    copyStrings(override.Delete),
+			Merge:     copyStringMap(override.Merge),
+		})
+	}
+	if kept.Source != nil || len(kept.Overrides) > 0 {
+		data, err := json.Marshal(kept)
+		if err != nil {
+			return nil, fmt.Errorf("cannot keep selectors: %v", err)
+		}
+		if out.Annotations == nil {
+			out.Annotations = map[string]string{}
+		}
+		out.Annotations[AnnotationSelectors] = string(data)
+	}
+	out.Status = v1alpha1.ConfigurationDistributionRuleStatus{Ready: in.Status.Ready}
+	for _, cs := range in.Status.Clusters {
+		out.Status.Clusters = append(out.Status.Clusters, v1alpha1.ClusterStatus(cs))
+	}
+	for _, po := range in.Status.PlannedOperations {
+		out.Status.PlannedOperations = append(out.Status.PlannedOperations, v1alpha1.PlannedOperation(po))
+	}
+	for _, c := range in.Status.Conflicts {
+		out.Status.Conflicts = append(out.Status.Conflicts, v1alpha1.Conflict{
+			Key:     c.Key,
+			Source:  c.Source,
+			Ignored: copyStrings(c.Ignored),
+		})
+	}
+	return out, nil
+}
+
+// ConvertFromV1alpha1 converts a v1alpha1 rule into a v1beta1 rule. Kept
+// label selectors are restored as long as the v1alpha1 selectors have not
+// been changed since. The selector key of rules without selector is kept
+// in an annotation.
+func ConvertFromV1alpha1(in *v1alpha1.ConfigurationDistributionRule) (*ConfigurationDistributionRule, error) {
+	kept := keptSelectors{}
+	if data, ok := in.Annotations[AnnotationSelectors]; ok {
+		if err := json.Unmarshal([]byte(data), &kept); err != nil {
+			return nil, fmt.Errorf("invalid annotation '%s': %v", AnnotationSelectors, err)
+		}
+	}
+	out := &ConfigurationDistributionRule{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: SchemeGroupVersion.String(),
+			Kind:       "ConfigurationDistributionRule",
+		},
+	}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	delete(out.Annotations, AnnotationSelectors)
+	delete(out.Annotations, AnnotationSelectorKey)
+	if len(out.Annotations) == 0 {
+		out.Annotations = nil
+	}
+	out.Spec = ConfigurationDistributionRuleSpec{
+		Mode: modeFromV1alpha1(in.Spec.Mode),
+		Source: Source{
+			Namespaces:         copyStrings(in.Spec.SourceNamespaces),
+			ServiceAccountName: in.Spec.ServiceAccountName,
+			Selector:           selectorFromV1alpha1(in.Spec.SelectorKey, in.Spec.Selector),
+			Template:           in.Spec.Template,
+			Parameters:         copyStringMap(in.Spec.Parameters),
+			Metadata: MetadataFilter{
+				AllowLabels:      copyStrings(in.Spec.Metadata.AllowLabels),
+				DenyLabels:       copyStrings(in.Spec.Metadata.DenyLabels),
+				AllowAnnotations: copyStrings(in.Spec.Metadata.AllowAnnotations),
+				DenyAnnotations:  copyStrings(in.Spec.Metadata.DenyAnnotations),
+			},
+		},
+		Target: Target{
+			Namespaces: copyStrings(in.Spec.Namespaces),
+		},
+		DeletionPolicy: deletionPolicyFromV1alpha1(in.Spec.DeletionPolicy),
+		DryRun:         in.Spec.DryRun,
+	}
+	if kept.Source != nil {
+		key, value, _ := selectorToV1alpha1(kept.Source)
+		if value == in.Spec.Selector && (value == "" || key == in.Spec.SelectorKey) {
+			out.Spec.Source.Selector = kept.Source
+		}
+	}
+	if out.Spec.Source.Selector == nil && in.Spec.SelectorKey != "" {
+		if out.Annotations == nil {
+			out.Annotations = map[string]string{}
+		}
+		out.Annotations[AnnotationSelectorKey] = in.Spec.SelectorKey
+	}
+	if in.Spec.Aggregate != nil {
+		out.Spec.Target.Aggregate = &Aggregate{Name: in.Spec.Aggregate.Name}
+	}
+	if in.Spec.Encryption != nil {
+		out.Spec.Target.Encryption = &Encryption{
+			ConfigMapName: in.Spec.Encryption.ConfigMapName,
+			ConfigMapKey:  in.Spec.Encryption.ConfigMapKey,
+			Namespaces:    copyStrings(in.Spec.Encryption.Namespaces),
+		}
+	}
+	for _, projection := range in.Spec.Projections {
+		out.Spec.Target.Projections = append(out.Spec.Target.Projections, Projection{
+			Kind:       SourceKind(projection.Kind),
+			Name:       projection.Name,
+			Keys:       copyStrings(projection.Keys),
+			TargetName: projection.TargetName,
+		})
+	}
+	for _, ref := range in.Spec.Sources {
+		out.Spec.Source.References = append(out.Spec.Source.References, SourceReference{
+			Kind:      SourceKind(ref.Kind),
+			Name:      ref.Name,
+			Namespace: ref.Namespace,
+		})
+	}
+	if in.Spec.SecretTypes != nil {
+		out.Spec.Source.SecretTypes = make(map[corev1.SecretType]corev1.SecretType, len(in.Spec.SecretTypes))
+		for from, to := range in.Spec.SecretTypes {
+			out.Spec.Source.SecretTypes[corev1.SecretType(from)] = corev1.SecretType(to)
+		}
+	}
+	for _, cluster := range in.Spec.Clusters {
+		out.Spec.Target.Clusters = append(out.Spec.Target.Clusters, Cluster{
+			Name:       cluster.Name,
+			SecretName: cluster.SecretName,
+			SecretKey:  cluster.SecretKey,
+			Namespaces: copyStrings(cluster.Namespaces),
+		})
+	}
+	for i, override := range in.Spec.Overrides {
+		selector := namespaceSelectorFromV1alpha1(override.Selector)
+		if ks, ok := kept.Overrides[i]; ok {
+			if ms, _ := namespaceSelectorToV1alpha1(ks); reflect.DeepEqual(ms, override.Selector) {
+				selector = ks
+			}
+		}
+		out.Spec.Target.Overrides = append(out.Spec.Target.Overrides, Override{
+			Namespace:         override.Namespace,
+			NamespaceSelector: selector,
+			Set:               copyStringMap(override.Set),
+			Delete:            copyStrings(override.Delete),
+			Merge:             copyStringMap(override.Merge),
+		})
+	}
+	out.Status.Ready = in.Status.Ready
+	for _, cs := range in.Status.Clusters {
+		out.Status.Clusters = append(out.Status.Clusters, ClusterStatus(cs))
+	}
+	for _, po := range in.Status.PlannedOperations {
+		out.Status.PlannedOperations = append(out.Status.PlannedOperations, PlannedOperation(po))
+	}
+	for _, c := range in.Status.Conflicts {
+		out.Status.Conflicts = append(out.Status.Conflicts, Conflict{
+			Key:     c.Key,
+			Source:  c.Source,
+			Ignored: copyStrings(c.Ignored),
+		})
+	}
+	return out, nil
+}
+
+//--------------------
+// SELECTORS
+//--------------------
+
+// selectorToV1alpha1 returns the v1alpha1 selector key and value of the
+// source selector. Selectors with more than one match label or with match
+// expressions are reduced to their first match label and not ok.
+func selectorToV1alpha1(selector *metav1.LabelSelector) (string, string, bool) {
+	if selector == nil {
+		return "", "", true
+	}
+	keys := make([]string, 0, len(selector.MatchLabels))
+	for key := range selector.MatchLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	ok := len(keys) <= 1 && len(selector.MatchExpressions) == 0
+	if len(keys) == 0 {
+		return "", "", ok
+	}
+	return keys[0], selector.MatchLabels[keys[0]], ok
+}
+
+// selectorFromV1alpha1 returns the source selector for the v1alpha1
+// selector key and value. An empty value selects everything.
+func selectorFromV1alpha1(key, value string) *metav1.LabelSelector {
+	if value == "" {
+		return nil
+	}
+	if key == "" {
+		key = v1alpha1.DefaultSelectorKey
+	}
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{key: value},
+	}
+}
+
+// namespaceSelectorToV1alpha1 returns the match labels of the namespace
+// selector. Selectors with match expressions are not ok.
+func namespaceSelectorToV1alpha1(selector *metav1.LabelSelector) (map[string]string, bool) {
+	if selector == nil {
+		return nil, true
+	}
+	return copyStringMap(selector.MatchLabels), len(selector.MatchExpressions) == 0
+}
+
+// namespaceSelectorFromV1alpha1 returns the namespace selector for the
+// v1alpha1 labels.
+func namespaceSelectorFromV1alpha1(lbls map[string]string) *metav1.LabelSelector {
+	if len(lbls) == 0 {
+		return nil
+	}
+	return &metav1.LabelSelector{
+		MatchLabels: copyStringMap(lbls),
+	}
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// modeToV1alpha1 returns the v1alpha1 value of the mode. Unknown
+// values are passed unchanged, so that validation can reject them.
+func modeToV1alpha1(mode DistributionMode) string {
+	if value, ok := modesV1alpha1[mode]; ok {
+		return value
+	}
+	return string(mode)
+}
+
+// modeFromV1alpha1 returns the mode for the v1alpha1 value.
+func modeFromV1alpha1(value string) DistributionMode {
+	for mode, v := range modesV1alpha1 {
+		if v == value {
+			return mode
+		}
+	}
+	return DistributionMode(value)
+}
+
+// deletionPolicyToV1alpha1 returns the v1alpha1 value of the deletion
+// policy. Unknown values are passed unchanged.
+func deletionPolicyToV1alpha1(policy DeletionPolicy) string {
+	if value, ok := deletionPoliciesV1alpha1[policy]; ok {
+		return value
+	}
+	return string(policy)
+}
+
+// deletionPolicyFromV1alpha1 returns the deletion policy for the
+// v1alpha1 value.
+func deletionPolicyFromV1alpha1(value string) DeletionPolicy {
+	for policy, v := range deletionPoliciesV1alpha1 {
+		if v == value {
+			return policy
+		}
+	}
+	return DeletionPolicy(value)
+}
+
+// EOF
@@ -0,0 +1,239 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package v1beta1 // import "tideland.dev/codis/api/v1beta1"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestRoundTripV1alpha1 tests that v1alpha1 rules converted to v1beta1
+// and back are unchanged.
+func TestRoundTripV1alpha1(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(rule *v1alpha1.ConfigurationDistributionRule)
+	}{
+		{
+			name:   "complete",
+			modify: func(rule *v1alpha1.ConfigurationDistributionRule) {},
+		}, {
+			name: "default selector key",
+			modify: func(rule *v1alpha1.ConfigurationDistributionRule) {
+				rule.Spec.SelectorKey = v1alpha1.DefaultSelectorKey
+			},
+		}, {
+			name: "no selector",
+			modify: func(rule *v1alpha1.ConfigurationDistributionRule) {
+				rule.Spec.SelectorKey = ""
+				rule.Spec.Selector = ""
+			},
+		}, {
+			name: "no selector with selector key",
+			modify: func(rule *v1alpha1.ConfigurationDistributionRule) {
+				rule.Spec.Selector = ""
+			},
+		}, {
+			name: "no selector with default selector key",
+			modify: func(rule *v1alpha1.ConfigurationDistributionRule) {
+				rule.Spec.SelectorKey = v1alpha1.DefaultSelectorKey
+				rule.Spec.Selector = ""
+			},
+		}, {
+			name: "kept selectors",
+			modify: func(rule *v1alpha1.ConfigurationDistributionRule) {
+				rule.Annotations[AnnotationSelectors] = `{"source":{"matchLabels":{"team":"platform","tier":"config"}},` +
+					`"overrides":{"1":{"matchLabels":{"stage":"dev"},"matchExpressions":[{"key":"tier","operator":"Exists"}]}}}`
+			},
+		}, {
+			name: "empty",
+			modify: func(rule *v1alpha1.ConfigurationDistributionRule) {
+				*rule = v1alpha1.ConfigurationDistributionRule{
+					TypeMeta:   rule.TypeMeta,
+					ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			alpha := testV1alpha1Rule()
+			test.modify(alpha)
+			beta, err := ConvertFromV1alpha1(alpha)
+			if err != nil {
+				t.Fatalf("cannot convert to v1beta1: %v", err)
+			}
+			back, err := ConvertToV1alpha1(beta)
+			if err != nil {
+				t.Fatalf("cannot convert back to v1alpha1: %v", err)
+			}
+			if !reflect.DeepEqual(back, alpha) {
+				t.Errorf("got rule\n%+v\nwant\n%+v", back, alpha)
+			}
+		})
+	}
+}
+
+// TestRoundTripV1beta1 tests that v1beta1 rules converted to v1alpha1
+// and back are unchanged, also with label selectors only kept in the
+// annotation of the v1alpha1 rule.
+func TestRoundTripV1beta1(t *testing.T) {
+	expressions := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "stage", Operator: metav1.LabelSelectorOpIn, Values: []string{"dev", "test"}},
+		},
+	}
+	tests := []struct {
+		name      string
+		selector  *metav1.LabelSelector
+		override  *metav1.LabelSelector
+		annotated bool
+	}{
+		{name: "no selectors"},
+		{name: "one match label", selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "platform"}}},
+		{
+			name:      "several match labels",
+			selector:  &metav1.LabelSelector{MatchLabels: map[string]string{"team": "platform", "tier": "config"}},
+			annotated: true,
+		},
+		{name: "match expressions", selector: expressions, annotated: true},
+		{name: "override match labels", override: &metav1.LabelSelector{MatchLabels: map[string]string{"stage": "dev"}}},
+		{name: "override match expressions", override: expressions, annotated: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			beta := testV1beta1Rule()
+			beta.Spec.Source.Selector = test.selector
+			beta.Spec.Target.Overrides[0].NamespaceSelector = test.override
+			alpha, err := ConvertToV1alpha1(beta)
+			if err != nil {
+				t.Fatalf("cannot convert to v1alpha1: %v", err)
+			}
+			if _, annotated := alpha.Annotations[AnnotationSelectors]; annotated != test.annotated {
+				t.Errorf("got kept selectors annotation %v, want %v", annotated, test.annotated)
+			}
+			back, err := ConvertFromV1alpha1(alpha)
+			if err != nil {
+				t.Fatalf("cannot convert back to v1beta1: %v", err)
+			}
+			if !reflect.DeepEqual(back, beta) {
+				t.Errorf("got rule\n%+v\nwant\n%+v", back, beta)
+			}
+		})
+	}
+}
+
+// TestChangedV1alpha1Selectors tests that kept label selectors are dropped
+// if the selectors of the v1alpha1 rule have been changed since.
+func TestChangedV1alpha1Selectors(t *testing.T) {
+	beta := testV1beta1Rule()
+	beta.Spec.Source.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "platform", "tier": "config"}}
+	alpha, err := ConvertToV1alpha1(beta)
+	if err != nil {
+		t.Fatalf("cannot convert to v1alpha1: %v", err)
+	}
+	alpha.Spec.Selector = "tools"
+	back, err := ConvertFromV1alpha1(alpha)
+	if err != nil {
+		t.Fatalf("cannot convert back to v1beta1: %v", err)
+	}
+	want := &metav1.LabelSelector{MatchLabels: map[string]string{"team": "tools"}}
+	if !reflect.DeepEqual(back.Spec.Source.Selector, want) {
+		t.Errorf("got selector %v, want %v", back.Spec.Source.Selector, want)
+	}
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// testV1alpha1Rule returns a v1alpha1 rule using all fields.
+func testV1alpha1Rule() *v1alpha1.ConfigurationDistributionRule {
+	return &v1alpha1.ConfigurationDistributionRule{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1alpha1.SchemeGroupVersion.String(),
+			Kind:       "ConfigurationDistributionRule",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test",
+			Namespace:   "default",
+			Annotations: map[string]string{"owner": "platform"},
+		},
+		Spec: v1alpha1.ConfigurationDistributionRuleSpec{
+			Mode:               "both",
+			SourceNamespaces:   []string{"default", "team-a"},
+			ServiceAccountName: "reader",
+			SelectorKey:        "team",
+			Selector:           "platform",
+			Sources:            []v1alpha1.SourceReference{{Kind: "Secret", Name: "registry", Namespace: "team-a"}},
+			Namespaces:         []string{"apps", "tools"},
+			Template:           true,
+			Parameters:         map[string]string{"domain": "example.com"},
+			SecretTypes:        map[string]string{string(corev1.SecretTypeBasicAuth): string(corev1.SecretTypeOpaque)},
+			Metadata: v1alpha1.MetadataFilter{
+				AllowLabels:      []string{"app.kubernetes.io/*"},
+				DenyLabels:       []string{"internal"},
+				AllowAnnotations: []string{"description"},
+				DenyAnnotations:  []string{"kubectl.kubernetes.io/*"},
+			},
+			Overrides: []v1alpha1.Override{
+				{Namespace: "apps", Set: map[string]string{"level": "debug"}, Delete: []string{"trace"}},
+				{Selector: map[string]string{"stage": "dev"}, Merge: map[string]string{"config.json": `{"debug":true}`}},
+			},
+			Aggregate:      &v1alpha1.Aggregate{Name: "platform"},
+			Projections:    []v1alpha1.Projection{{Kind: "Secret", Name: "ca", Keys: []string{"ca.crt"}, TargetName: "ca-bundle"}},
+			Encryption:     &v1alpha1.Encryption{ConfigMapName: "codis-key", ConfigMapKey: "public.pem", Namespaces: []string{"apps"}},
+			Clusters:       []v1alpha1.Cluster{{Name: "edge", SecretName: "edge", SecretKey: "kubeconfig", Namespaces: []string{"apps"}}},
+			DeletionPolicy: "delete",
+			DryRun:         true,
+		},
+		Status: v1alpha1.ConfigurationDistributionRuleStatus{
+			Ready:             true,
+			Clusters:          []v1alpha1.ClusterStatus{{Name: "edge", Healthy: true}},
+			PlannedOperations: []v1alpha1.PlannedOperation{{Action: "create", Kind: "configmap", Name: "platform", Namespace: "apps"}},
+			Conflicts:         []v1alpha1.Conflict{{Key: "level", Source: "default/a", Ignored: []string{"default/b"}}},
+		},
+	}
+}
+
+// testV1beta1Rule returns a v1beta1 rule with one override.
+func testV1beta1Rule() *ConfigurationDistributionRule {
+	return &ConfigurationDistributionRule{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: SchemeGroupVersion.String(),
+			Kind:       "ConfigurationDistributionRule",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: ConfigurationDistributionRuleSpec{
+			Mode: ModeConfigMap,
+			Source: Source{
+				Namespaces: []string{"default"},
+			},
+			Target: Target{
+				Namespaces: []string{"apps"},
+				Overrides:  []Override{{Set: map[string]string{"level": "debug"}}},
+			},
+			DeletionPolicy: DeletionPolicyRetain,
+		},
+	}
+}
+
+// EOF
@@ -0,0 +1,16 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+// Package v1beta1 contains the API version v1beta1 of the configuration
+// distribution rules.
+//
+// +k8s:deepcopy-gen=package
+// +groupName=k8s.tideland.dev
+// +groupGoName=Codis
+package v1beta1 // import "tideland.dev/codis/api/v1beta1"
+
+// EOF
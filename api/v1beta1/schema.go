@@ -0,0 +1,295 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package v1beta1 // import "tideland.dev/codis/api/v1beta1"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+const (
+	groupName    = "k8s.tideland.dev"
+	groupVersion = "v1beta1"
+)
+
+var (
+	// SchemeGroupVersion describes the CRD.
+	SchemeGroupVersion = schema.GroupVersion{Group: groupName, Version: groupVersion}
+
+	// SchemeBuilder creates a scheme builder for the known types.
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+
+	// AddToScheme points to a function to create the known types.
+	AddToScheme = SchemeBuilder.AddToScheme
+)
+
+// Resource takes an unqualified resource and returns a group qualified one.
+func Resource(resource string) schema.GroupResource {
+	return SchemeGroupVersion.WithResource(resource).GroupResource()
+}
+
+// addKnownTypes adds our new types.
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion,
+		&ConfigurationDistributionRule{},
+		&ConfigurationDistributionRuleList{},
+	)
+
+	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
+	return nil
+}
+
+//--------------------
+// ENUMS
+//--------------------
+
+// DistributionMode defines which kinds of sources a rule distributes.
+// +kubebuilder:validation:Enum=ConfigMap;Secret;Both
+type DistributionMode string
+
+// Distribution modes.
+const (
+	ModeConfigMap DistributionMode = "ConfigMap"
+	ModeSecret    DistributionMode = "Secret"
+	ModeBoth      DistributionMode = "Both"
+)
+
+// SourceKind defines the kind of a referenced source.
+// +kubebuilder:validation:Enum=ConfigMap;Secret
+type SourceKind string
+
+// Source kinds.
+const (
+	SourceKindConfigMap SourceKind = "ConfigMap"
+	SourceKindSecret    SourceKind = "Secret"
+)
+
+// DeletionPolicy defines what happens to the copies of a deleted source.
+// +kubebuilder:validation:Enum=Retain;Delete
+type DeletionPolicy string
+
+// Deletion policies.
+const (
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+)
+
+//--------------------
+// SCHEMA
+//--------------------
+
+// ConfigurationDistributionRuleSpec specifies one configuration distribution rule.
+type ConfigurationDistributionRuleSpec struct {
+	Mode DistributionMode `json:"mode,omitempty"`
+	// +optional
+	Source Source `json:"source"`
+	// +optional
+	Target         Target         `json:"target"`
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	DryRun         bool           `json:"dryRun,omitempty"`
+}
+
+// Source selects the distributed ConfigMaps and Secrets in the source
+// namespaces and controls how they are copied. Without namespaces the
+// sources are taken out of the namespace of the rule, other namespaces
+// have to grant read access to the service account of the rule. Sources
+// are selected by label or referenced by name.
+type Source struct {
+	Namespaces         []string                                `json:"namespaces,omitempty"`
+	ServiceAccountName string                                  `json:"serviceAccountName,omitempty"`
+	Selector           *metav1.LabelSelector                   `json:"selector,omitempty"`
+	References         []SourceReference                       `json:"references,omitempty"`
+	Template           bool                                    `json:"template,omitempty"`
+	Parameters         map[string]string                       `json:"parameters,omitempty"`
+	SecretTypes        map[corev1.SecretType]corev1.SecretType `json:"secretTypes,omitempty"`
+	Metadata           MetadataFilter                          `json:"metadata,omitempty"`
+}
+
+// SourceReference references one source by its kind and name. Without a
+// namespace it is the namespace of the rule.
+type SourceReference struct {
+	Kind      SourceKind `json:"kind"`
+	Name      string     `json:"name"`
+	Namespace string     `json:"namespace,omitempty"`
+}
+
+// Target describes the local namespaces and remote clusters receiving
+// the copies and how their data is patched. With an aggregate all
+// ConfigMaps are merged into one copy, projections copy keys of single
+// sources into copies of the other kind. With an encryption the data of
+// Secret copies is encrypted per target namespace.
+type Target struct {
+	Namespaces  []string     `json:"namespaces,omitempty"`
+	Clusters    []Cluster    `json:"clusters,omitempty"`
+	Overrides   []Override   `json:"overrides,omitempty"`
+	Aggregate   *Aggregate   `json:"aggregate,omitempty"`
+	Projections []Projection `json:"projections,omitempty"`
+	Encryption  *Encryption  `json:"encryption,omitempty"`
+}
+
+// Aggregate describes the ConfigMap the selected ConfigMaps are merged
+// into. Referenced sources take precedence in the order of their
+// definition, followed by the selected ones ordered by namespace and
+// name. The first source containing a key wins.
+type Aggregate struct {
+	Name string `json:"name"`
+}
+
+// Projection projects the keys of the selected source of the kind with
+// the name into a copy of the other kind, e.g. a CA bundle stored as
+// Secret into a ConfigMap. Without a target name the copy has the name
+// of the source.
+type Projection struct {
+	Kind       SourceKind `json:"kind"`
+	Name       string     `json:"name"`
+	Keys       []string   `json:"keys"`
+	TargetName string     `json:"targetName,omitempty"`
+}
+
+// Encryption describes the encryption of the data of Secret copies with
+// the RSA public key stored in a ConfigMap in each encrypted target
+// namespace. Without namespaces all target namespaces are encrypted.
+type Encryption struct {
+	ConfigMapName string   `json:"configMapName"`
+	ConfigMapKey  string   `json:"configMapKey,omitempty"`
+	Namespaces    []string `json:"namespaces,omitempty"`
+}
+
+// Cluster describes a remote cluster copies are distributed to. Its kubeconfig
+// is stored with the given key in a Secret in the namespace of the rule.
+type Cluster struct {
+	Name       string `json:"name"`
+	SecretName string `json:"secretName"`
+	SecretKey  string `json:"secretKey,omitempty"`
+	// +optional
+	Namespaces []string `json:"namespaces"`
+}
+
+// Override describes a patch of the distributed data for the target namespaces
+// matching the namespace name or the namespace label selector.
+type Override struct {
+	Namespace         string                `json:"namespace,omitempty"`
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	Set               map[string]string     `json:"set,omitempty"`
+	Delete            []string              `json:"delete,omitempty"`
+	Merge             map[string]string     `json:"merge,omitempty"`
+}
+
+// MetadataFilter controls which labels and annotations of a source are
+// propagated to its copies. Entries are keys or prefixes ending with '*'.
+// Without allow entries all keys are allowed, deny entries always win.
+type MetadataFilter struct {
+	AllowLabels      []string `json:"allowLabels,omitempty"`
+	DenyLabels       []string `json:"denyLabels,omitempty"`
+	AllowAnnotations []string `json:"allowAnnotations,omitempty"`
+	DenyAnnotations  []string `json:"denyAnnotations,omitempty"`
+}
+
+// ConfigurationDistributionRuleStatus contains the observed state of a rule.
+type ConfigurationDistributionRuleStatus struct {
+	// +optional
+	Ready             bool               `json:"ready"`
+	Clusters          []ClusterStatus    `json:"clusters,omitempty"`
+	PlannedOperations []PlannedOperation `json:"plannedOperations,omitempty"`
+	Conflicts         []Conflict         `json:"conflicts,omitempty"`
+}
+
+// Conflict describes a key of the aggregate with different values in
+// several sources. The value of the source is used, the ones of the
+// ignored sources are dropped.
+type Conflict struct {
+	Key    string `json:"key"`
+	Source string `json:"source"`
+	// +optional
+	Ignored []string `json:"ignored"`
+}
+
+// ClusterStatus contains the health of a remote cluster.
+type ClusterStatus struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
+}
+
+// PlannedOperation describes a write of a copy which would be performed
+// if the rule would not be in dry-run mode.
+type PlannedOperation struct {
+	Action    string `json:"action"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Cluster   string `json:"cluster,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=cdr;codisrule;rule
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name=Mode,type=string,JSONPath=.spec.mode
+// +kubebuilder:printcolumn:name=Targets,type=string,JSONPath=.spec.target.namespaces
+// +kubebuilder:printcolumn:name=Ready,type=boolean,JSONPath=.status.ready
+// +kubebuilder:printcolumn:name=Age,type=date,JSONPath=.metadata.creationTimestamp
+
+// ConfigurationDistributionRule contains the Kubernetes base informations and the spec.
+type ConfigurationDistributionRule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ConfigurationDistributionRuleSpec   `json:"spec"`
+	Status ConfigurationDistributionRuleStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+
+// ConfigurationDistributionRuleList contains the Kubernetes base informations and a list of rules.
+type ConfigurationDistributionRuleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ConfigurationDistributionRule `json:"items"`
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// copyStringMap returns a copy of the given map, nil stays nil.
+func copyStringMap(in map[string]string) map[string]string {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+// copyStrings returns a copy of the given slice, nil stays nil.
+func copyStrings(in []string) []string {
+	if in == nil {
+		return nil
+	}
+	out := make([]string, len(in))
+	copy(out, in)
+	return out
+}
+
+// EOF
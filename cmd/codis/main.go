@@ -12,16 +12,32 @@ package main // import "tideland.dev/codis/cmd/codis"
 //--------------------
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"time"
 
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 
 	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+	"tideland.dev/codis/pkg/client/clientset/versioned"
+	codisinformers "tideland.dev/codis/pkg/client/informers/externalversions"
 	"tideland.dev/codis/pkg/codis"
+	"tideland.dev/codis/pkg/tracing"
+	"tideland.dev/codis/pkg/webhook"
 )
 
 //--------------------
@@ -29,35 +45,199 @@ import (
 //--------------------
 
 func main() {
-	log.Printf("Starting the Tideland Configuration Distributor (CoDis) ...")
+	// Subcommands.
+	if len(os.Args) > 1 && os.Args[1] == "plan" {
+		codisv1alpha1.AddToScheme(scheme.Scheme)
+		os.Exit(plan(os.Args[2:]))
+	}
 
 	// Configuration.
 	var (
-		kubeconfig string
-		masterURL  string
-		namespace  string
-		rulename   string
+		kubeconfig     string
+		masterURL      string
+		namespace      string
+		rulename       string
+		metricsAddress string
+		healthAddress  string
+		pprofAddress   string
+		otlpEndpoint   string
+		logFormat      string
+		logVerbosity   int
+		dryRun         bool
+		webhookAddress string
+		tlsCertFile    string
+		tlsKeyFile     string
+		strictNS       bool
+		leaderElect    bool
+		leaseName      string
+		checksumKey    string
 	)
 	flag.StringVar(&kubeconfig, "kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
 	flag.StringVar(&masterURL, "master", "", "Address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
 	flag.StringVar(&namespace, "namespace", "default", "Namespace of the managed configuration distributor rule.")
 	flag.StringVar(&rulename, "rulename", "default-rule", "Name of the managed configuration distributor rule.")
+	flag.StringVar(&metricsAddress, "metrics-address", ":8080", "Address of the Prometheus metrics endpoint. Empty to disable it.")
+	flag.StringVar(&healthAddress, "health-address", ":8081", "Address of the health and readiness endpoints. Empty to disable them.")
+	flag.StringVar(&pprofAddress, "pprof-address", "", "Address of the pprof endpoints. Empty to disable them.")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "", "Endpoint of the OTLP/HTTP collector receiving traces, e.g. 'http://collector:4318'. Empty to disable tracing.")
+	flag.StringVar(&logFormat, "log-format", codis.LogFormatText, "Format of the log output, 'text' or 'json'.")
+	flag.IntVar(&logVerbosity, "log-verbosity", 0, "Verbosity of the log output, higher values log more details.")
+	flag.BoolVar(&dryRun, "dry-run", false, "Only plan the changes and send the API requests with 'DryRun: All' instead of persisting them.")
+	flag.StringVar(&webhookAddress, "webhook-address", "", "Address of the HTTPS admission webhook endpoints. Empty to disable them.")
+	flag.StringVar(&tlsCertFile, "tls-cert-file", "/etc/codis/tls/tls.crt", "Path to the TLS certificate of the admission webhooks.")
+	flag.StringVar(&tlsKeyFile, "tls-key-file", "/etc/codis/tls/tls.key", "Path to the TLS key of the admission webhooks.")
+	flag.BoolVar(&leaderElect, "leader-elect", false, "Elect a leader among several replicas, only the leader distributes.")
+	flag.StringVar(&leaseName, "lease-name", "", "Name of the Lease used for the leader election. Defaults to 'codis-<rulename>'.")
+	flag.BoolVar(&strictNS, "strict-namespaces", false, "Let the admission webhook reject rules with unknown target namespaces.")
+	flag.StringVar(&checksumKey, "checksum-key-file", "", "Path to the key of the checksums of encrypted copies. Without it a random key is used and all encrypted copies are encrypted again after each start.")
 	flag.Parse()
 
+	logger, err := codis.NewLogger(os.Stderr, logFormat, logVerbosity)
+	if err != nil {
+		log.Fatalf("Cannot create logger: %v", err)
+	}
+	logger.Info("starting the Tideland Configuration Distributor (CoDis)")
+
 	config, err := clientcmd.BuildConfigFromFlags(masterURL, kubeconfig)
 	if err != nil {
-		log.Fatalf("Cannot read controller configuration: %v", err)
+		logger.Error(err, "cannot read controller configuration")
+		os.Exit(1)
+	}
+
+	// Tracing.
+	var tp trace.TracerProvider
+	if otlpEndpoint != "" {
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+			logger.Error(err, "cannot export traces")
+		}))
+		provider, err := tracing.NewProvider(context.Background(), otlpEndpoint, "codis")
+		if err != nil {
+			logger.Error(err, "cannot init tracing")
+			os.Exit(1)
+		}
+		defer provider.Shutdown(context.Background())
+		tp = provider
 	}
 
 	// Configuration distributor.
-	cd, err := codis.New(config, namespace, rulename)
+	codisv1alpha1.AddToScheme(scheme.Scheme)
+	key, err := readChecksumKey(checksumKey)
 	if err != nil {
-		log.Fatalf("Cannot init configuration distributor: %v", err)
+		logger.Error(err, "cannot read checksum key")
+		os.Exit(1)
+	}
+	cd, err := codis.New(config, namespace, rulename, dryRun, key, logger, tp)
+	if err != nil {
+		logger.Error(err, "cannot init configuration distributor")
+		os.Exit(1)
+	}
+
+	// HTTP endpoints.
+	if metricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", cd.MetricsHandler())
+		go serve(logger, "metrics", metricsAddress, mux)
+	}
+	if healthAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/healthz", cd.HealthzHandler())
+		mux.Handle("/readyz", cd.ReadyzHandler())
+		go serve(logger, "health", healthAddress, mux)
+	}
+	if webhookAddress != "" {
+		client, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			logger.Error(err, "cannot create webhook client")
+			os.Exit(1)
+		}
+		rules, err := versioned.NewForConfig(config)
+		if err != nil {
+			logger.Error(err, "cannot create webhook rule client")
+			os.Exit(1)
+		}
+		// The validator looks up other rules in an indexed informer.
+		factory := codisinformers.NewSharedInformerFactory(rules, 30*time.Second)
+		informer := factory.Codis().V1alpha1().ConfigurationDistributionRules().Informer()
+		if err := informer.AddIndexers(codisv1alpha1.RuleIndexers()); err != nil {
+			logger.Error(err, "cannot add webhook rule indexers")
+			os.Exit(1)
+		}
+		factory.Start(context.Background().Done())
+		mux := http.NewServeMux()
+		mux.Handle("/convert", webhook.NewConverter())
+		mux.Handle("/default", webhook.NewDefaulter())
+		mux.Handle("/validate", webhook.NewValidator(client, codisv1alpha1.NewRuleIndex(informer.GetIndexer()), strictNS))
+		go func() {
+			if !cache.WaitForCacheSync(context.Background().Done(), informer.HasSynced) {
+				logger.Error(nil, "cannot sync webhook rule informer")
+				os.Exit(1)
+			}
+			serveTLS(logger, "webhook", webhookAddress, tlsCertFile, tlsKeyFile, mux)
+		}()
+	}
+	if pprofAddress != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		go serve(logger, "pprof", pprofAddress, mux)
 	}
-	codisv1alpha1.AddToScheme(scheme.Scheme)
 
-	log.Printf("Run the configuration distributor ...")
+	if leaderElect {
+		if leaseName == "" {
+			leaseName = "codis-" + rulename
+		}
+		identity, err := os.Hostname()
+		if err != nil {
+			logger.Error(err, "cannot get identity for leader election")
+			os.Exit(1)
+		}
+		logger.Info("electing the configuration distributor", "lease", leaseName, "identity", identity)
+		if err := cd.RunElected(context.Background(), leaseName, identity); err != nil {
+			logger.Error(err, "cannot elect configuration distributor")
+		}
+		// Leadership is lost, restart to compete again.
+		os.Exit(1)
+	}
+	logger.Info("running the configuration distributor")
 	cd.Run(context.Background())
 }
 
+// readChecksumKey reads the checksum key out of the file. Without a file
+// the key is empty.
+func readChecksumKey(filename string) ([]byte, error) {
+	if filename == "" {
+		return nil, nil
+	}
+	key, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read file: %v", err)
+	}
+	key = bytes.TrimSpace(key)
+	if len(key) == 0 {
+		return nil, errors.New("file is empty")
+	}
+	return key, nil
+}
+
+// serve runs an HTTP server for the named endpoints on the given address.
+func serve(logger logr.Logger, name, address string, handler http.Handler) {
+	logger.Info("serving endpoints", "endpoints", name, "address", address)
+	if err := http.ListenAndServe(address, handler); err != nil {
+		logger.Error(err, "cannot serve endpoints", "endpoints", name)
+		os.Exit(1)
+	}
+}
+
+// serveTLS runs an HTTPS server for the named endpoints on the given address.
+func serveTLS(logger logr.Logger, name, address, certFile, keyFile string, handler http.Handler) {
+	logger.Info("serving endpoints", "endpoints", name, "address", address)
+	if err := http.ListenAndServeTLS(address, certFile, keyFile, handler); err != nil {
+		logger.Error(err, "cannot serve endpoints", "endpoints", name)
+		os.Exit(1)
+	}
+}
+
 // EOF
@@ -0,0 +1,236 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package main // import "tideland.dev/codis/cmd/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+	codisv1beta1 "tideland.dev/codis/api/v1beta1"
+	"tideland.dev/codis/pkg/codis"
+)
+
+//--------------------
+// PLAN
+//--------------------
+
+// plan runs the plan subcommand printing the changes of a rule as diff
+// and returns the exit code. Like "kubectl diff" it is 0 without changes,
+// 1 with changes, and 2 in case of an error.
+func plan(args []string) int {
+	var (
+		kubeconfig string
+		masterURL  string
+		rulefile   string
+		manifests  string
+		keyfile    string
+	)
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	fs.StringVar(&kubeconfig, "kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
+	fs.StringVar(&masterURL, "master", "", "Address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+	fs.StringVar(&rulefile, "rule", "", "Path to the YAML file of the planned rule.")
+	fs.StringVar(&manifests, "manifests", "", "Directory of ConfigMap, Secret, and Namespace manifests to plan against instead of a live cluster.")
+	fs.StringVar(&keyfile, "checksum-key-file", "", "Path to the checksum key of the controller. Without it encrypted copies are planned as updated.")
+	fs.Parse(args)
+
+	logger, err := codis.NewLogger(os.Stderr, codis.LogFormatText, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create logger: %v\n", err)
+		return 2
+	}
+	rule, err := readRule(rulefile)
+	if err != nil {
+		logger.Error(err, "cannot read rule")
+		return 2
+	}
+	checksumKey, err := readChecksumKey(keyfile)
+	if err != nil {
+		logger.Error(err, "cannot read checksum key")
+		return 2
+	}
+	var client kubernetes.Interface
+	if manifests != "" {
+		logger.Info("planning against manifests, access of the rule to the sources is not checked", "manifests", manifests)
+		client, err = manifestClient(manifests)
+	} else {
+		client, err = clusterClient(masterURL, kubeconfig)
+	}
+	if err != nil {
+		logger.Error(err, "cannot create client")
+		return 2
+	}
+	// Failing copies do not stop the plan, so the changes of the others
+	// are printed in any case.
+	changes, planErr := codis.Plan(context.Background(), client, rule, checksumKey, logger)
+	for _, change := range changes {
+		diff, err := change.Diff()
+		if err != nil {
+			logger.Error(err, "cannot diff change")
+			return 2
+		}
+		fmt.Print(diff)
+	}
+	if planErr != nil {
+		logger.Error(planErr, "cannot plan rule")
+		return 2
+	}
+	if len(changes) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readRule reads the rule out of the YAML file. Rules in version
+// v1beta1 are converted.
+func readRule(filename string) (*codisv1alpha1.ConfigurationDistributionRule, error) {
+	if filename == "" {
+		return nil, errors.New("no rule file given")
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var tm metav1.TypeMeta
+	if err := yaml.Unmarshal(data, &tm); err != nil {
+		return nil, fmt.Errorf("invalid rule file '%s': %v", filename, err)
+	}
+	if tm.Kind != "ConfigurationDistributionRule" {
+		return nil, fmt.Errorf("rule file '%s' contains no rule", filename)
+	}
+	var rule *codisv1alpha1.ConfigurationDistributionRule
+	switch tm.APIVersion {
+	case codisv1alpha1.SchemeGroupVersion.String():
+		rule = &codisv1alpha1.ConfigurationDistributionRule{}
+		err = yaml.UnmarshalStrict(data, rule)
+	case codisv1beta1.SchemeGroupVersion.String():
+		var beta codisv1beta1.ConfigurationDistributionRule
+		if err = yaml.UnmarshalStrict(data, &beta); err == nil {
+			rule, err = codisv1beta1.ConvertToV1alpha1(&beta)
+		}
+	default:
+		err = fmt.Errorf("unsupported API version '%s'", tm.APIVersion)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule file '%s': %v", filename, err)
+	}
+	if rule.GetNamespace() == "" {
+		rule.SetNamespace("default")
+	}
+	return rule, nil
+}
+
+// clusterClient returns the client for a live cluster.
+func clusterClient(masterURL, kubeconfig string) (kubernetes.Interface, error) {
+	config, err := clientcmd.BuildConfigFromFlags(masterURL, kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// manifestClient returns a fake client containing the ConfigMaps, Secrets,
+// and Namespaces of all YAML and JSON manifests in the directory. Other
+// objects are ignored. As manifests contain no RBAC all access reviews
+// are allowed.
+func manifestClient(dir string) (kubernetes.Interface, error) {
+	var objs []runtime.Object
+	decoder := scheme.Codecs.UniversalDeserializer()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+		for {
+			doc, err := reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("cannot read manifest '%s': %v", path, err)
+			}
+			if len(bytes.TrimSpace(doc)) == 0 {
+				continue
+			}
+			obj, _, err := decoder.Decode(doc, nil, nil)
+			if runtime.IsNotRegisteredError(err) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("cannot decode manifest '%s': %v", path, err)
+			}
+			switch o := obj.(type) {
+			case *corev1.ConfigMap:
+				if o.GetNamespace() == "" {
+					o.SetNamespace("default")
+				}
+				objs = append(objs, o)
+			case *corev1.Secret:
+				if o.GetNamespace() == "" {
+					o.SetNamespace("default")
+				}
+				// Merge string data like the API server does.
+				for key, value := range o.StringData {
+					if o.Data == nil {
+						o.Data = map[string][]byte{}
+					}
+					o.Data[key] = []byte(value)
+				}
+				o.StringData = nil
+				objs = append(objs, o)
+			case *corev1.Namespace:
+				objs = append(objs, o)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	client := fake.NewSimpleClientset(objs...)
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview).DeepCopy()
+		sar.Status.Allowed = true
+		sar.Status.Reason = "access is not checked against manifests"
+		return true, sar, nil
+	})
+	return client, nil
+}
+
+// EOF
@@ -0,0 +1,75 @@
+#!/usr/bin/env bash
+#
+# Tideland CoDis
+#
+# Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+#
+# All rights reserved. Use of this source code is governed
+# by the new BSD license
+#
+# Generates deepcopy functions, clientset, listers, and informers of the
+# API as well as the CRD out of its kubebuilder markers. Needs deepcopy-gen,
+# client-gen, lister-gen, and informer-gen of k8s.io/code-generator and
+# controller-gen of sigs.k8s.io/controller-tools in the PATH.
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+MODULE=tideland.dev/codis
+ROOT=$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)
+HEADER=${ROOT}/hack/boilerplate.go.txt
+VERSIONS="v1alpha1 v1beta1"
+
+# The generators name the group packages after the parent directory of
+# the versions, so they read the API through a link named like the group.
+mkdir -p "${ROOT}/_codegen"
+ln -sfn ../api "${ROOT}/_codegen/codis"
+trap 'rm -rf "${ROOT}/_codegen"' EXIT
+
+INPUTS=()
+CLIENT_INPUTS=()
+for VERSION in ${VERSIONS}; do
+	INPUTS+=("${MODULE}/_codegen/codis/${VERSION}")
+	CLIENT_INPUTS+=(--input "${MODULE}/_codegen/codis/${VERSION}")
+done
+
+cd "${ROOT}"
+rm -rf "${ROOT}/pkg/client"
+
+deepcopy-gen \
+	--output-file zz_generated.deepcopy.go \
+	--go-header-file "${HEADER}" \
+	"${INPUTS[@]}"
+
+client-gen \
+	--clientset-name versioned \
+	--input-base "" \
+	"${CLIENT_INPUTS[@]}" \
+	--output-dir "${ROOT}/pkg/client/clientset" \
+	--output-pkg "${MODULE}/pkg/client/clientset" \
+	--go-header-file "${HEADER}"
+
+lister-gen \
+	--output-dir "${ROOT}/pkg/client/listers" \
+	--output-pkg "${MODULE}/pkg/client/listers" \
+	--go-header-file "${HEADER}" \
+	"${INPUTS[@]}"
+
+informer-gen \
+	--versioned-clientset-package "${MODULE}/pkg/client/clientset/versioned" \
+	--listers-package "${MODULE}/pkg/client/listers" \
+	--output-dir "${ROOT}/pkg/client/informers" \
+	--output-pkg "${MODULE}/pkg/client/informers" \
+	--go-header-file "${HEADER}" \
+	"${INPUTS[@]}"
+
+grep -rl "${MODULE}/_codegen/codis/" "${ROOT}/pkg/client" | xargs sed -i "s#${MODULE}/_codegen/codis/#${MODULE}/api/#g"
+
+# The CRD gets the conversion webhook of hack/crd-conversion.yaml, which
+# controller-gen cannot generate.
+controller-gen crd:crdVersions=v1 paths="${MODULE}/api/..." output:crd:dir="${ROOT}/_codegen/crd"
+awk -v conversion="${ROOT}/hack/crd-conversion.yaml" '
+	/^  versions:$/ { while ((getline line < conversion) > 0) print line }
+	{ print }
+' "${ROOT}/_codegen/crd/k8s.tideland.dev_configurationdistributionrules.yaml" > "${ROOT}/config/cdr-codis.yaml"
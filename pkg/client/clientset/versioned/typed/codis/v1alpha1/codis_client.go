@@ -0,0 +1,92 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	http "net/http"
+
+	rest "k8s.io/client-go/rest"
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+	scheme "tideland.dev/codis/pkg/client/clientset/versioned/scheme"
+)
+
+type CodisV1alpha1Interface interface {
+	RESTClient() rest.Interface
+	ConfigurationDistributionRulesGetter
+}
+
+// CodisV1alpha1Client is used to interact with features provided by the k8s.tideland.dev group.
+type CodisV1alpha1Client struct {
+	restClient rest.Interface
+}
+
+func (c *CodisV1alpha1Client) ConfigurationDistributionRules(namespace string) ConfigurationDistributionRuleInterface {
+	return newConfigurationDistributionRules(c, namespace)
+}
+
+// NewForConfig creates a new CodisV1alpha1Client for the given config.
+// NewForConfig is equivalent to NewForConfigAndClient(c, httpClient),
+// where httpClient was generated with rest.HTTPClientFor(c).
+func NewForConfig(c *rest.Config) (*CodisV1alpha1Client, error) {
+	config := *c
+	setConfigDefaults(&config)
+	httpClient, err := rest.HTTPClientFor(&config)
+	if err != nil {
+		return nil, err
+	}
+	return NewForConfigAndClient(&config, httpClient)
+}
+
+// NewForConfigAndClient creates a new CodisV1alpha1Client for the given config and http client.
+// Note the http client provided takes precedence over the configured transport values.
+func NewForConfigAndClient(c *rest.Config, h *http.Client) (*CodisV1alpha1Client, error) {
+	config := *c
+	setConfigDefaults(&config)
+	client, err := rest.RESTClientForConfigAndClient(&config, h)
+	if err != nil {
+		return nil, err
+	}
+	return &CodisV1alpha1Client{client}, nil
+}
+
+// NewForConfigOrDie creates a new CodisV1alpha1Client for the given config and
+// panics if there is an error in the config.
+func NewForConfigOrDie(c *rest.Config) *CodisV1alpha1Client {
+	client, err := NewForConfig(c)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+// New creates a new CodisV1alpha1Client for the given RESTClient.
+func New(c rest.Interface) *CodisV1alpha1Client {
+	return &CodisV1alpha1Client{c}
+}
+
+func setConfigDefaults(config *rest.Config) {
+	gv := codisv1alpha1.SchemeGroupVersion
+	config.GroupVersion = &gv
+	config.APIPath = "/apis"
+	config.NegotiatedSerializer = rest.CodecFactoryForGeneratedClient(scheme.Scheme, scheme.Codecs).WithoutConversion()
+
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+}
+
+// RESTClient returns a RESTClient that is used to communicate
+// with API server by this client implementation.
+func (c *CodisV1alpha1Client) RESTClient() rest.Interface {
+	if c == nil {
+		return nil
+	}
+	return c.restClient
+}
@@ -0,0 +1,45 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	gentype "k8s.io/client-go/gentype"
+	v1alpha1 "tideland.dev/codis/api/v1alpha1"
+	codisv1alpha1 "tideland.dev/codis/pkg/client/clientset/versioned/typed/codis/v1alpha1"
+)
+
+// fakeConfigurationDistributionRules implements ConfigurationDistributionRuleInterface
+type fakeConfigurationDistributionRules struct {
+	*gentype.FakeClientWithList[*v1alpha1.ConfigurationDistributionRule, *v1alpha1.ConfigurationDistributionRuleList]
+	Fake *FakeCodisV1alpha1
+}
+
+func newFakeConfigurationDistributionRules(fake *FakeCodisV1alpha1, namespace string) codisv1alpha1.ConfigurationDistributionRuleInterface {
+	return &fakeConfigurationDistributionRules{
+		gentype.NewFakeClientWithList[*v1alpha1.ConfigurationDistributionRule, *v1alpha1.ConfigurationDistributionRuleList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("configurationdistributionrules"),
+			v1alpha1.SchemeGroupVersion.WithKind("ConfigurationDistributionRule"),
+			func() *v1alpha1.ConfigurationDistributionRule { return &v1alpha1.ConfigurationDistributionRule{} },
+			func() *v1alpha1.ConfigurationDistributionRuleList {
+				return &v1alpha1.ConfigurationDistributionRuleList{}
+			},
+			func(dst, src *v1alpha1.ConfigurationDistributionRuleList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.ConfigurationDistributionRuleList) []*v1alpha1.ConfigurationDistributionRule {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1alpha1.ConfigurationDistributionRuleList, items []*v1alpha1.ConfigurationDistributionRule) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
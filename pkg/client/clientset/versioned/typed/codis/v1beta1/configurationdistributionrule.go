@@ -0,0 +1,65 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	context "context"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+	codisv1beta1 "tideland.dev/codis/api/v1beta1"
+	scheme "tideland.dev/codis/pkg/client/clientset/versioned/scheme"
+)
+
+// ConfigurationDistributionRulesGetter has a method to return a ConfigurationDistributionRuleInterface.
+// A group's client should implement this interface.
+type ConfigurationDistributionRulesGetter interface {
+	ConfigurationDistributionRules(namespace string) ConfigurationDistributionRuleInterface
+}
+
+// ConfigurationDistributionRuleInterface has methods to work with ConfigurationDistributionRule resources.
+type ConfigurationDistributionRuleInterface interface {
+	Create(ctx context.Context, configurationDistributionRule *codisv1beta1.ConfigurationDistributionRule, opts v1.CreateOptions) (*codisv1beta1.ConfigurationDistributionRule, error)
+	Update(ctx context.Context, configurationDistributionRule *codisv1beta1.ConfigurationDistributionRule, opts v1.UpdateOptions) (*codisv1beta1.ConfigurationDistributionRule, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, configurationDistributionRule *codisv1beta1.ConfigurationDistributionRule, opts v1.UpdateOptions) (*codisv1beta1.ConfigurationDistributionRule, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*codisv1beta1.ConfigurationDistributionRule, error)
+	List(ctx context.Context, opts v1.ListOptions) (*codisv1beta1.ConfigurationDistributionRuleList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *codisv1beta1.ConfigurationDistributionRule, err error)
+	ConfigurationDistributionRuleExpansion
+}
+
+// configurationDistributionRules implements ConfigurationDistributionRuleInterface
+type configurationDistributionRules struct {
+	*gentype.ClientWithList[*codisv1beta1.ConfigurationDistributionRule, *codisv1beta1.ConfigurationDistributionRuleList]
+}
+
+// newConfigurationDistributionRules returns a ConfigurationDistributionRules
+func newConfigurationDistributionRules(c *CodisV1beta1Client, namespace string) *configurationDistributionRules {
+	return &configurationDistributionRules{
+		gentype.NewClientWithList[*codisv1beta1.ConfigurationDistributionRule, *codisv1beta1.ConfigurationDistributionRuleList](
+			"configurationdistributionrules",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *codisv1beta1.ConfigurationDistributionRule {
+				return &codisv1beta1.ConfigurationDistributionRule{}
+			},
+			func() *codisv1beta1.ConfigurationDistributionRuleList {
+				return &codisv1beta1.ConfigurationDistributionRuleList{}
+			},
+		),
+	}
+}
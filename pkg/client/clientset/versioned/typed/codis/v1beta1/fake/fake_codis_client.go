@@ -0,0 +1,31 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	rest "k8s.io/client-go/rest"
+	testing "k8s.io/client-go/testing"
+	v1beta1 "tideland.dev/codis/pkg/client/clientset/versioned/typed/codis/v1beta1"
+)
+
+type FakeCodisV1beta1 struct {
+	*testing.Fake
+}
+
+func (c *FakeCodisV1beta1) ConfigurationDistributionRules(namespace string) v1beta1.ConfigurationDistributionRuleInterface {
+	return newFakeConfigurationDistributionRules(c, namespace)
+}
+
+// RESTClient returns a RESTClient that is used to communicate
+// with API server by this client implementation.
+func (c *FakeCodisV1beta1) RESTClient() rest.Interface {
+	var ret *rest.RESTClient
+	return ret
+}
@@ -0,0 +1,43 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	gentype "k8s.io/client-go/gentype"
+	v1beta1 "tideland.dev/codis/api/v1beta1"
+	codisv1beta1 "tideland.dev/codis/pkg/client/clientset/versioned/typed/codis/v1beta1"
+)
+
+// fakeConfigurationDistributionRules implements ConfigurationDistributionRuleInterface
+type fakeConfigurationDistributionRules struct {
+	*gentype.FakeClientWithList[*v1beta1.ConfigurationDistributionRule, *v1beta1.ConfigurationDistributionRuleList]
+	Fake *FakeCodisV1beta1
+}
+
+func newFakeConfigurationDistributionRules(fake *FakeCodisV1beta1, namespace string) codisv1beta1.ConfigurationDistributionRuleInterface {
+	return &fakeConfigurationDistributionRules{
+		gentype.NewFakeClientWithList[*v1beta1.ConfigurationDistributionRule, *v1beta1.ConfigurationDistributionRuleList](
+			fake.Fake,
+			namespace,
+			v1beta1.SchemeGroupVersion.WithResource("configurationdistributionrules"),
+			v1beta1.SchemeGroupVersion.WithKind("ConfigurationDistributionRule"),
+			func() *v1beta1.ConfigurationDistributionRule { return &v1beta1.ConfigurationDistributionRule{} },
+			func() *v1beta1.ConfigurationDistributionRuleList { return &v1beta1.ConfigurationDistributionRuleList{} },
+			func(dst, src *v1beta1.ConfigurationDistributionRuleList) { dst.ListMeta = src.ListMeta },
+			func(list *v1beta1.ConfigurationDistributionRuleList) []*v1beta1.ConfigurationDistributionRule {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1beta1.ConfigurationDistributionRuleList, items []*v1beta1.ConfigurationDistributionRule) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
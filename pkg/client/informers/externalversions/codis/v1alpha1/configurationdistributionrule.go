@@ -0,0 +1,93 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+	time "time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+	codegencodisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+	versioned "tideland.dev/codis/pkg/client/clientset/versioned"
+	internalinterfaces "tideland.dev/codis/pkg/client/informers/externalversions/internalinterfaces"
+	codisv1alpha1 "tideland.dev/codis/pkg/client/listers/codis/v1alpha1"
+)
+
+// ConfigurationDistributionRuleInformer provides access to a shared informer and lister for
+// ConfigurationDistributionRules.
+type ConfigurationDistributionRuleInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() codisv1alpha1.ConfigurationDistributionRuleLister
+}
+
+type configurationDistributionRuleInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewConfigurationDistributionRuleInformer constructs a new informer for ConfigurationDistributionRule type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewConfigurationDistributionRuleInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredConfigurationDistributionRuleInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredConfigurationDistributionRuleInformer constructs a new informer for ConfigurationDistributionRule type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredConfigurationDistributionRuleInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.CodisV1alpha1().ConfigurationDistributionRules(namespace).List(context.Background(), options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.CodisV1alpha1().ConfigurationDistributionRules(namespace).Watch(context.Background(), options)
+			},
+			ListWithContextFunc: func(ctx context.Context, options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.CodisV1alpha1().ConfigurationDistributionRules(namespace).List(ctx, options)
+			},
+			WatchFuncWithContext: func(ctx context.Context, options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.CodisV1alpha1().ConfigurationDistributionRules(namespace).Watch(ctx, options)
+			},
+		},
+		&codegencodisv1alpha1.ConfigurationDistributionRule{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *configurationDistributionRuleInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredConfigurationDistributionRuleInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *configurationDistributionRuleInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&codegencodisv1alpha1.ConfigurationDistributionRule{}, f.defaultInformer)
+}
+
+func (f *configurationDistributionRuleInformer) Lister() codisv1alpha1.ConfigurationDistributionRuleLister {
+	return codisv1alpha1.NewConfigurationDistributionRuleLister(f.Informer().GetIndexer())
+}
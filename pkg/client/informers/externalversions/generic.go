@@ -0,0 +1,58 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+// Code generated by informer-gen. DO NOT EDIT.
+
+package externalversions
+
+import (
+	fmt "fmt"
+
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	cache "k8s.io/client-go/tools/cache"
+	v1alpha1 "tideland.dev/codis/api/v1alpha1"
+	v1beta1 "tideland.dev/codis/api/v1beta1"
+)
+
+// GenericInformer is type of SharedIndexInformer which will locate and delegate to other
+// sharedInformers based on type
+type GenericInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() cache.GenericLister
+}
+
+type genericInformer struct {
+	informer cache.SharedIndexInformer
+	resource schema.GroupResource
+}
+
+// Informer returns the SharedIndexInformer.
+func (f *genericInformer) Informer() cache.SharedIndexInformer {
+	return f.informer
+}
+
+// Lister returns the GenericLister.
+func (f *genericInformer) Lister() cache.GenericLister {
+	return cache.NewGenericLister(f.Informer().GetIndexer(), f.resource)
+}
+
+// ForResource gives generic access to a shared informer of the matching type
+// TODO extend this to unknown resources with a client pool
+func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource) (GenericInformer, error) {
+	switch resource {
+	// Group=k8s.tideland.dev, Version=v1alpha1
+	case v1alpha1.SchemeGroupVersion.WithResource("configurationdistributionrules"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Codis().V1alpha1().ConfigurationDistributionRules().Informer()}, nil
+
+		// Group=k8s.tideland.dev, Version=v1beta1
+	case v1beta1.SchemeGroupVersion.WithResource("configurationdistributionrules"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Codis().V1beta1().ConfigurationDistributionRules().Informer()}, nil
+
+	}
+
+	return nil, fmt.Errorf("no informer found for %v", resource)
+}
@@ -0,0 +1,61 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+// ConfigurationDistributionRuleLister helps list ConfigurationDistributionRules.
+// All objects returned here must be treated as read-only.
+type ConfigurationDistributionRuleLister interface {
+	// List lists all ConfigurationDistributionRules in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*codisv1alpha1.ConfigurationDistributionRule, err error)
+	// ConfigurationDistributionRules returns an object that can list and get ConfigurationDistributionRules.
+	ConfigurationDistributionRules(namespace string) ConfigurationDistributionRuleNamespaceLister
+	ConfigurationDistributionRuleListerExpansion
+}
+
+// configurationDistributionRuleLister implements the ConfigurationDistributionRuleLister interface.
+type configurationDistributionRuleLister struct {
+	listers.ResourceIndexer[*codisv1alpha1.ConfigurationDistributionRule]
+}
+
+// NewConfigurationDistributionRuleLister returns a new ConfigurationDistributionRuleLister.
+func NewConfigurationDistributionRuleLister(indexer cache.Indexer) ConfigurationDistributionRuleLister {
+	return &configurationDistributionRuleLister{listers.New[*codisv1alpha1.ConfigurationDistributionRule](indexer, codisv1alpha1.Resource("configurationdistributionrule"))}
+}
+
+// ConfigurationDistributionRules returns an object that can list and get ConfigurationDistributionRules.
+func (s *configurationDistributionRuleLister) ConfigurationDistributionRules(namespace string) ConfigurationDistributionRuleNamespaceLister {
+	return configurationDistributionRuleNamespaceLister{listers.NewNamespaced[*codisv1alpha1.ConfigurationDistributionRule](s.ResourceIndexer, namespace)}
+}
+
+// ConfigurationDistributionRuleNamespaceLister helps list and get ConfigurationDistributionRules.
+// All objects returned here must be treated as read-only.
+type ConfigurationDistributionRuleNamespaceLister interface {
+	// List lists all ConfigurationDistributionRules in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*codisv1alpha1.ConfigurationDistributionRule, err error)
+	// Get retrieves the ConfigurationDistributionRule from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*codisv1alpha1.ConfigurationDistributionRule, error)
+	ConfigurationDistributionRuleNamespaceListerExpansion
+}
+
+// configurationDistributionRuleNamespaceLister implements the ConfigurationDistributionRuleNamespaceLister
+// interface.
+type configurationDistributionRuleNamespaceLister struct {
+	listers.ResourceIndexer[*codisv1alpha1.ConfigurationDistributionRule]
+}
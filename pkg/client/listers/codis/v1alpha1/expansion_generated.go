@@ -0,0 +1,18 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+// ConfigurationDistributionRuleListerExpansion allows custom methods to be added to
+// ConfigurationDistributionRuleLister.
+type ConfigurationDistributionRuleListerExpansion interface{}
+
+// ConfigurationDistributionRuleNamespaceListerExpansion allows custom methods to be added to
+// ConfigurationDistributionRuleNamespaceLister.
+type ConfigurationDistributionRuleNamespaceListerExpansion interface{}
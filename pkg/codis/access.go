@@ -0,0 +1,123 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+// accessTTL is the time the result of an access review is reused.
+const accessTTL = time.Minute
+
+//--------------------
+// SOURCE ACCESS
+//--------------------
+
+// accessReview is the cached result of an access review.
+type accessReview struct {
+	err     error
+	expires time.Time
+}
+
+// sourceNamespace checks if the namespace is one of the source namespaces
+// of the rule.
+func (cd *ConfigurationDistributor) sourceNamespace(namespace string) bool {
+	for _, ns := range codisv1alpha1.SourceNamespaces(cd.rule) {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSourceAccess checks if the service account of the rule may read
+// sources of the kind in the namespace. Sources in the namespace of the
+// rule are always allowed, for all others the owners of the namespace
+// have to grant the access. So a rule cannot read Secrets out of
+// namespaces it does not own. Service accounts which may read the kind
+// in all namespaces, like the one of the controller, are refused, as
+// their access is not granted by the owners of the namespace.
+func (cd *ConfigurationDistributor) checkSourceAccess(ctx context.Context, kind, namespace string) error {
+	if namespace == cd.namespace {
+		return nil
+	}
+	user := "system:serviceaccount:" + cd.namespace + ":" + cd.rule.Spec.ServiceAccountName
+	key := user + "/" + kind + "/" + namespace
+	cd.mu.Lock()
+	review, ok := cd.reviews[key]
+	cd.mu.Unlock()
+	if ok && time.Now().Before(review.expires) {
+		return review.err
+	}
+	review = accessReview{
+		err:     cd.reviewSourceAccess(ctx, user, kind, namespace),
+		expires: time.Now().Add(accessTTL),
+	}
+	cd.mu.Lock()
+	cd.reviews[key] = review
+	cd.mu.Unlock()
+	return review.err
+}
+
+// reviewSourceAccess asks the API server if the user may get sources of
+// the kind in the namespace but not in all namespaces.
+func (cd *ConfigurationDistributor) reviewSourceAccess(ctx context.Context, user, kind, namespace string) error {
+	allowed, err := cd.reviewAccess(ctx, user, kind, metav1.NamespaceAll)
+	if err != nil {
+		return fmt.Errorf("cannot review access to %ss in all namespaces: %v", kind, err)
+	}
+	if allowed {
+		return fmt.Errorf("%s may read %ss in all namespaces and cannot be used to read sources", user, kind)
+	}
+	allowed, err = cd.reviewAccess(ctx, user, kind, namespace)
+	if err != nil {
+		return fmt.Errorf("cannot review access to %ss in namespace '%s': %v", kind, namespace, err)
+	}
+	if !allowed {
+		return fmt.Errorf("%s may not read %ss in namespace '%s'", user, kind, namespace)
+	}
+	return nil
+}
+
+// reviewAccess asks the API server if the user may get objects of the
+// kind in the namespace. An empty namespace means all namespaces.
+func (cd *ConfigurationDistributor) reviewAccess(ctx context.Context, user, kind, namespace string) (bool, error) {
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user,
+			Groups: []string{"system:serviceaccounts", "system:serviceaccounts:" + cd.namespace},
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "get",
+				Resource:  kind + "s",
+			},
+		},
+	}
+	reviewed, err := cd.client.AuthorizationV1().SubjectAccessReviews().Create(ctx, sar, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return reviewed.Status.Allowed, nil
+}
+
+// EOF
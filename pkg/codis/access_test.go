@@ -0,0 +1,98 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestSourceAccess tests that sources outside of the namespace of the rule
+// are only read if their namespace grants the access to the service account
+// of the rule, and that service accounts reading all namespaces are refused.
+func TestSourceAccess(t *testing.T) {
+	tests := []struct {
+		name           string
+		serviceAccount string
+		namespace      string
+		err            string
+	}{
+		{name: "own namespace", serviceAccount: "reader", namespace: "default"},
+		{name: "granted", serviceAccount: "reader", namespace: "team-a"},
+		{name: "not granted", serviceAccount: "reader", namespace: "team-b", err: "may not read secrets in namespace 'team-b'"},
+		{name: "cluster-wide", serviceAccount: "sa-codis", namespace: "team-a", err: "may read secrets in all namespaces"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+				attrs := sar.Spec.ResourceAttributes
+				switch {
+				case strings.HasSuffix(sar.Spec.User, ":sa-codis"):
+					sar.Status.Allowed = true
+				case strings.HasSuffix(sar.Spec.User, ":reader"):
+					sar.Status.Allowed = attrs.Namespace == "team-a"
+				}
+				return true, sar, nil
+			})
+			cd := newTestDistributor(t, client, &codisv1alpha1.ConfigurationDistributionRule{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: codisv1alpha1.ConfigurationDistributionRuleSpec{
+					Mode:               "secret",
+					SourceNamespaces:   []string{"default", "team-a", "team-b"},
+					ServiceAccountName: test.serviceAccount,
+					Namespaces:         []string{"apps"},
+				},
+			})
+			err := cd.checkSourceAccess(context.Background(), "secret", test.namespace)
+			switch {
+			case test.err == "" && err != nil:
+				t.Errorf("got error %v, want access", err)
+			case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+				t.Errorf("got error %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+// TestLocalTarget tests that source namespaces are no local targets.
+func TestLocalTarget(t *testing.T) {
+	cd := newTestDistributor(t, fake.NewSimpleClientset(), &codisv1alpha1.ConfigurationDistributionRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: codisv1alpha1.ConfigurationDistributionRuleSpec{
+			Mode:             "secret",
+			SourceNamespaces: []string{"default", "team-a"},
+			Namespaces:       []string{"apps", "team-a"},
+		},
+	})
+	for namespace, want := range map[string]bool{"apps": true, "team-a": false, "tools": false} {
+		if _, ok := cd.localTarget(namespace); ok != want {
+			t.Errorf("got local target %v for namespace %s, want %v", ok, namespace, want)
+		}
+	}
+}
+
+// EOF
@@ -0,0 +1,201 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// AGGREGATION
+//--------------------
+
+// aggregates checks if the rule merges its ConfigMaps into an aggregate.
+func (cd *ConfigurationDistributor) aggregates() bool {
+	return cd.rule.Spec.Aggregate != nil && cd.distributes("configmap")
+}
+
+// aggregated checks if the ConfigMap is part of the aggregate. Projected
+// ConfigMaps are not.
+func (cd *ConfigurationDistributor) aggregated(in *corev1.ConfigMap) bool {
+	if !cd.aggregates() {
+		return false
+	}
+	_, projected := cd.projectionFor("configmap", in.GetName())
+	return !projected
+}
+
+// applyAggregate merges the selected ConfigMaps and applies the aggregate
+// to the given targets. Without any source the copies of the aggregate
+// are only deleted if the deletion policy of the rule says so.
+func (cd *ConfigurationDistributor) applyAggregate(targets []target) {
+	name := cd.rule.Spec.Aggregate.Name
+	ctx, span := cd.startReconcileSpan("configmap", name)
+	ins := cd.aggregateSources(ctx)
+	if len(ins) == 0 {
+		cd.endReconcileSpan(span, &outcome{}, nil)
+		cd.setConflicts(nil)
+		if cd.rule.Spec.DeletionPolicy == deletionPolicyDelete {
+			cd.removeCopies(cd.rule, "configmap", "configmaps", name, targets)
+		}
+		return
+	}
+	cd.log.Info("applying aggregate", "kind", "configmap", "name", name, "sources", len(ins), "dryRun", cd.dryRun())
+	defer cd.beginReconcile("configmap")()
+	agg, conflicts := cd.aggregateOf(ins)
+	for _, c := range conflicts {
+		cd.log.Info("conflicting key in aggregate", "name", name, "key", c.Key, "source", c.Source, "ignored", c.Ignored)
+	}
+	cd.setConflicts(conflicts)
+	o := &outcome{}
+	for _, t := range targets {
+		tctx, tspan := cd.startTargetSpan(ctx, "configmap", name, t)
+		var action string
+		out, err := cd.configMapFor(agg, t)
+		if err == nil {
+			action, err = cd.writeConfigMap(tctx, t, out)
+			cd.reportTarget(t, err)
+		}
+		cd.reportApply(tspan, cd.rule, "configmap", name, t, action, err)
+		o.add("configmap", name, t, action, err)
+	}
+	cd.finishReconcile(span, cd.rule, "configmap", name, o)
+}
+
+// aggregateSources returns the selected ConfigMaps in the order of their
+// precedence. Sources which may not be read are logged and left out.
+func (cd *ConfigurationDistributor) aggregateSources(ctx context.Context) []*corev1.ConfigMap {
+	var ins []*corev1.ConfigMap
+	for _, obj := range cd.cmInformer.GetStore().List() {
+		in := obj.(*corev1.ConfigMap)
+		if !cd.sourceNamespace(in.GetNamespace()) || !cd.selects("configmap", in) || !cd.aggregated(in) {
+			continue
+		}
+		if err := cd.checkSourceAccess(ctx, "configmap", in.GetNamespace()); err != nil {
+			cd.log.Error(err, "cannot aggregate source", "kind", "configmap", "name", in.GetName())
+			continue
+		}
+		ins = append(ins, in)
+	}
+	sort.Slice(ins, func(i, j int) bool {
+		pi, pj := cd.precedence(ins[i]), cd.precedence(ins[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return sourceKey(ins[i]) < sourceKey(ins[j])
+	})
+	return ins
+}
+
+// precedence returns the position of the ConfigMap in the referenced
+// sources of the rule. Sources only selected by label come after them.
+func (cd *ConfigurationDistributor) precedence(in *corev1.ConfigMap) int {
+	for i, ref := range cd.rule.Spec.Sources {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = cd.namespace
+		}
+		if strings.EqualFold(ref.Kind, "configmap") && ref.Name == in.GetName() && namespace == in.GetNamespace() {
+			return i
+		}
+	}
+	return len(cd.rule.Spec.Sources)
+}
+
+// aggregateOf merges the ConfigMaps into the aggregate. The first
+// ConfigMap containing a key wins, keys with different values in later
+// ConfigMaps are returned as conflicts.
+func (cd *ConfigurationDistributor) aggregateOf(ins []*corev1.ConfigMap) (*corev1.ConfigMap, []codisv1alpha1.Conflict) {
+	agg := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cd.rule.Spec.Aggregate.Name,
+			Namespace: cd.namespace,
+		},
+	}
+	if cd.rule.Spec.Selector != "" {
+		agg.Labels = map[string]string{cd.rule.Spec.SelectorKey: cd.rule.Spec.Selector}
+	}
+	values := map[string]interface{}{}
+	owners := map[string]string{}
+	ignored := map[string][]string{}
+	take := func(in *corev1.ConfigMap, key string, value interface{}) bool {
+		current, ok := values[key]
+		if !ok {
+			values[key] = value
+			owners[key] = sourceKey(in)
+			return true
+		}
+		if !reflect.DeepEqual(current, value) {
+			ignored[key] = append(ignored[key], sourceKey(in))
+		}
+		return false
+	}
+	for _, in := range ins {
+		for key, value := range in.Data {
+			if take(in, key, value) {
+				if agg.Data == nil {
+					agg.Data = map[string]string{}
+				}
+				agg.Data[key] = value
+			}
+		}
+		for key, value := range in.BinaryData {
+			if take(in, key, value) {
+				if agg.BinaryData == nil {
+					agg.BinaryData = map[string][]byte{}
+				}
+				agg.BinaryData[key] = value
+			}
+		}
+	}
+	var keys []string
+	for key := range ignored {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var conflicts []codisv1alpha1.Conflict
+	for _, key := range keys {
+		conflicts = append(conflicts, codisv1alpha1.Conflict{
+			Key:     key,
+			Source:  owners[key],
+			Ignored: ignored[key],
+		})
+	}
+	return agg, conflicts
+}
+
+// setConflicts sets the conflicts of the aggregate and writes them into
+// the status of the rule if they changed.
+func (cd *ConfigurationDistributor) setConflicts(conflicts []codisv1alpha1.Conflict) {
+	cd.mu.Lock()
+	changed := !reflect.DeepEqual(conflicts, cd.conflicts)
+	cd.conflicts = conflicts
+	cd.mu.Unlock()
+	if changed {
+		cd.updateStatus()
+	}
+}
+
+// sourceKey returns the namespace and name of a source.
+func sourceKey(in metav1.Object) string {
+	return in.GetNamespace() + "/" + in.GetName()
+}
+
+// EOF
@@ -0,0 +1,175 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestAggregate tests the precedence of the sources of an aggregate and
+// the conflicts of keys with different values.
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		name       string
+		sources    []runtime.Object
+		refs       []codisv1alpha1.SourceReference
+		data       map[string]string
+		binaryData map[string][]byte
+		conflicts  []codisv1alpha1.Conflict
+	}{
+		{
+			name:    "disjoint keys",
+			sources: objects(aggregated("default", "a", "x", "1"), aggregated("default", "b", "y", "2")),
+			data:    map[string]string{"x": "1", "y": "2"},
+		}, {
+			name:    "equal values",
+			sources: objects(aggregated("default", "a", "k", "1"), aggregated("default", "b", "k", "1")),
+			data:    map[string]string{"k": "1"},
+		}, {
+			name:    "selected by name",
+			sources: objects(aggregated("default", "b", "k", "2"), aggregated("default", "a", "k", "1")),
+			data:    map[string]string{"k": "1"},
+			conflicts: []codisv1alpha1.Conflict{
+				{Key: "k", Source: "default/a", Ignored: []string{"default/b"}},
+			},
+		}, {
+			name:    "selected by namespace",
+			sources: objects(aggregated("team-a", "a", "k", "2"), aggregated("default", "z", "k", "1")),
+			data:    map[string]string{"k": "1"},
+			conflicts: []codisv1alpha1.Conflict{
+				{Key: "k", Source: "default/z", Ignored: []string{"team-a/a"}},
+			},
+		}, {
+			name:    "referenced first",
+			sources: objects(aggregated("default", "a", "k", "1"), aggregated("default", "b", "k", "2")),
+			refs:    []codisv1alpha1.SourceReference{{Kind: "ConfigMap", Name: "b"}},
+			data:    map[string]string{"k": "2"},
+			conflicts: []codisv1alpha1.Conflict{
+				{Key: "k", Source: "default/b", Ignored: []string{"default/a"}},
+			},
+		}, {
+			name:    "reference order",
+			sources: objects(aggregated("default", "a", "k", "1"), aggregated("default", "b", "k", "2"), aggregated("team-a", "c", "k", "3")),
+			refs: []codisv1alpha1.SourceReference{
+				{Kind: "ConfigMap", Name: "c", Namespace: "team-a"},
+				{Kind: "ConfigMap", Name: "b"},
+			},
+			data: map[string]string{"k": "3"},
+			conflicts: []codisv1alpha1.Conflict{
+				{Key: "k", Source: "team-a/c", Ignored: []string{"default/b", "default/a"}},
+			},
+		}, {
+			name: "several keys",
+			sources: objects(
+				aggregated("default", "a", "k", "1", "l", "1"),
+				aggregated("default", "b", "k", "2", "l", "1", "m", "2"),
+				aggregated("default", "c", "m", "3"),
+			),
+			data: map[string]string{"k": "1", "l": "1", "m": "2"},
+			conflicts: []codisv1alpha1.Conflict{
+				{Key: "k", Source: "default/a", Ignored: []string{"default/b"}},
+				{Key: "m", Source: "default/b", Ignored: []string{"default/c"}},
+			},
+		}, {
+			name: "binary data",
+			sources: objects(
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "default", Labels: map[string]string{"rule": "test"}},
+					BinaryData: map[string][]byte{"k": {1}},
+				},
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "default", Labels: map[string]string{"rule": "test"}},
+					BinaryData: map[string][]byte{"k": {2}},
+				},
+			),
+			binaryData: map[string][]byte{"k": {1}},
+			conflicts: []codisv1alpha1.Conflict{
+				{Key: "k", Source: "default/a", Ignored: []string{"default/b"}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(test.sources...)
+			client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+				sar.Status.Allowed = sar.Spec.ResourceAttributes.Namespace != metav1.NamespaceAll
+				return true, sar, nil
+			})
+			cd := newTestDistributor(t, client, &codisv1alpha1.ConfigurationDistributionRule{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: codisv1alpha1.ConfigurationDistributionRuleSpec{
+					Mode:             "configmap",
+					Selector:         "test",
+					SourceNamespaces: []string{"default", "team-a"},
+					Sources:          test.refs,
+					Namespaces:       []string{"apps"},
+					Aggregate:        &codisv1alpha1.Aggregate{Name: "platform"},
+				},
+			})
+
+			cd.applyAggregate(cd.targets())
+			agg, err := client.CoreV1().ConfigMaps("apps").Get(context.Background(), "platform", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("cannot get aggregate: %v", err)
+			}
+			if !equalContent(agg.Data, test.data) || !equalContent(agg.BinaryData, test.binaryData) {
+				t.Errorf("got aggregate %v %v, want %v %v", agg.Data, agg.BinaryData, test.data, test.binaryData)
+			}
+			if !reflect.DeepEqual(cd.conflicts, test.conflicts) {
+				t.Errorf("got conflicts %v, want %v", cd.conflicts, test.conflicts)
+			}
+		})
+	}
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// aggregated returns a selected ConfigMap with the keys and values.
+func aggregated(namespace, name string, kvs ...string) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"rule": "test"}},
+		Data:       map[string]string{},
+	}
+	for i := 0; i < len(kvs); i += 2 {
+		cm.Data[kvs[i]] = kvs[i+1]
+	}
+	return cm
+}
+
+// objects returns the ConfigMaps as objects of a fake client.
+func objects(cms ...*corev1.ConfigMap) []runtime.Object {
+	var objs []runtime.Object
+	for _, cm := range cms {
+		objs = append(objs, cm)
+	}
+	return objs
+}
+
+// EOF
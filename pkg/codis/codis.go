@@ -13,19 +13,34 @@ package codis // import "tideland.dev/codis/pkg/codis"
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 
 	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+	codisv1beta1 "tideland.dev/codis/api/v1beta1"
+	"tideland.dev/codis/pkg/client/clientset/versioned"
+	codisclientv1alpha1 "tideland.dev/codis/pkg/client/clientset/versioned/typed/codis/v1alpha1"
+	codisinformers "tideland.dev/codis/pkg/client/informers/externalversions"
+	"tideland.dev/codis/pkg/tracing"
 )
 
 //--------------------
@@ -38,87 +53,216 @@ type ConfigurationDistributor struct {
 	client        kubernetes.Interface
 	namespace     string
 	rulename      string
-	ruleInterface codisv1alpha1.RuleInterface
+	ctx           context.Context
+	rules         codisclientv1alpha1.ConfigurationDistributionRuleInterface
 	rule          *codisv1alpha1.ConfigurationDistributionRule
+	ruleFactory   codisinformers.SharedInformerFactory
 	ruleInformer  cache.SharedIndexInformer
+	factory       informers.SharedInformerFactory
 	cmInformer    cache.SharedIndexInformer
 	scrtInformer  cache.SharedIndexInformer
 	nsInformer    cache.SharedIndexInformer
+	selector      labels.Selector
+	nsSelectors   []labels.Selector
+	mu            sync.Mutex
+	queue         workqueue.TypedInterface[*handling]
+	clusters      map[string]*remoteCluster
+	reviews       map[string]accessReview
+	dryRunAll     bool
+	checksumKey   []byte
+	changes       *changeRecorder
+	planned       []codisv1alpha1.PlannedOperation
+	conflicts     []codisv1alpha1.Conflict
+	metrics       *metrics
+	recorder      record.EventRecorder
+	log           logr.Logger
+	traceProvider trace.TracerProvider
+	tracer        trace.Tracer
 }
 
-// New creates a new configuration distribution engine.
-func New(config *rest.Config, namespace, rulename string) (*ConfigurationDistributor, error) {
-	cd := &ConfigurationDistributor{
-		config:    config,
-		namespace: namespace,
-		rulename:  rulename,
-	}
-	// Init rule interface.
-	namespaceableRuleInterface, err := codisv1alpha1.NewForConfig(cd.config)
+// New creates a new configuration distribution engine. All output
+// is written to the given structured logger, reconciles and their API
+// calls are traced with the tracer provider if it is not nil. In dry-run
+// mode no changes are persisted, independent of the rule. The checksum
+// key protects the checksums of encrypted copies, without one a random
+// key is used.
+func New(config *rest.Config, namespace, rulename string, dryRun bool, checksumKey []byte, log logr.Logger, tp trace.TracerProvider) (*ConfigurationDistributor, error) {
+	checksumKey, err := checksumKeyOrRandom(checksumKey)
 	if err != nil {
-		return nil, fmt.Errorf("cannot create namespaceable rule interface: %v", err)
+		return nil, err
 	}
-	cd.ruleInterface = namespaceableRuleInterface.Namespace(namespace)
-	rule, err := cd.ruleInterface.Get(cd.rulename, metav1.GetOptions{})
+	cd := &ConfigurationDistributor{
+		config:      config,
+		namespace:   namespace,
+		rulename:    rulename,
+		dryRunAll:   dryRun,
+		checksumKey: checksumKey,
+		clusters:    make(map[string]*remoteCluster),
+		reviews:     make(map[string]accessReview),
+		queue:       workqueue.NewTyped[*handling](),
+		log:         log.WithValues("rule", namespace+"/"+rulename),
+	}
+	cd.setTracing(tp)
+	if tp != nil {
+		cd.config = rest.CopyConfig(config)
+		tracing.WrapConfig(cd.config, tp)
+	}
+	// Init rule client.
+	codisClient, err := versioned.NewForConfig(cd.config)
 	if err != nil {
-		// In case of an error the controller allows a later loading based
-		// on an event.
-		cd.rule = rule
+		return nil, fmt.Errorf("cannot create rule client: %v", err)
+	}
+	cd.rules = codisClient.CodisV1alpha1().ConfigurationDistributionRules(namespace)
+	rule, err := cd.rules.Get(context.Background(), cd.rulename, metav1.GetOptions{})
+	if err == nil {
+		// In case of an error the rule stays unset and the controller
+		// allows a later loading based on an event.
+		cd.setRule(rule)
 	}
 	// Init client.
-	client, err := kubernetes.NewForConfig(config)
+	client, err := kubernetes.NewForConfig(cd.config)
 	if err != nil {
 		return nil, fmt.Errorf("cannot connect cluster: %v", err)
 	}
 	cd.client = client
-	// Init informers.
-	cd.ruleInformer = codisv1alpha1.NewRuleInformerWithInterface(cd.ruleInterface).Informer()
-	factory := informers.NewSharedInformerFactory(cd.client, 30*time.Second)
-	cd.cmInformer = factory.Core().V1().ConfigMaps().Informer()
-	cd.scrtInformer = factory.Core().V1().Secrets().Informer()
-	cd.nsInformer = factory.Core().V1().Namespaces().Informer()
+	cd.recorder = newRecorder(client)
+	// Init informers. The rule informer only watches the own rule.
+	cd.ruleFactory = codisinformers.NewSharedInformerFactoryWithOptions(codisClient, 30*time.Second,
+		codisinformers.WithNamespace(namespace),
+		codisinformers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", rulename).String()
+		}),
+	)
+	cd.ruleInformer = cd.ruleFactory.Codis().V1alpha1().ConfigurationDistributionRules().Informer()
+	cd.factory = informers.NewSharedInformerFactory(cd.client, 30*time.Second)
+	cd.cmInformer = cd.factory.Core().V1().ConfigMaps().Informer()
+	cd.scrtInformer = cd.factory.Core().V1().Secrets().Informer()
+	cd.nsInformer = cd.factory.Core().V1().Namespaces().Informer()
+	cd.metrics = newMetrics(cd)
 	return cd, nil
 }
 
-// Run executes the configuration distributor.
+// Run executes the configuration distributor. The first distribution
+// starts after the caches of all informers are synced.
 func (cd *ConfigurationDistributor) Run(ctx context.Context) {
-	cd.ruleInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+	cd.mu.Lock()
+	cd.ctx = ctx
+	cd.mu.Unlock()
+	if err := cd.startInformers(ctx); err != nil {
+		cd.log.Error(err, "cannot start informers")
+		return
+	}
+	// The rule is added again with all objects of the synced caches,
+	// so its handler performs the first distribution.
+	cd.ruleInformer.AddEventHandler(cd.serialized(cache.ResourceEventHandlerFuncs{
 		AddFunc:    cd.addRuleHandler,
 		UpdateFunc: cd.updateRuleHandler,
 		DeleteFunc: cd.deleteRuleHandler,
-	})
-	cd.cmInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+	}, true))
+	cd.cmInformer.AddEventHandler(cd.serialized(cache.ResourceEventHandlerFuncs{
 		AddFunc:    cd.addConfigMapHandler,
 		UpdateFunc: cd.updateConfigMapHandler,
-	})
-	cd.scrtInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: cd.deleteConfigMapHandler,
+	}, false))
+	cd.scrtInformer.AddEventHandler(cd.serialized(cache.ResourceEventHandlerFuncs{
 		AddFunc:    cd.addSecretHandler,
 		UpdateFunc: cd.updateSecretHandler,
-	})
-	cd.nsInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: cd.deleteSecretHandler,
+	}, false))
+	cd.nsInformer.AddEventHandler(cd.serialized(cache.ResourceEventHandlerFuncs{
 		AddFunc: cd.addNamespaceHandler,
-	})
-
-	go cd.ruleInformer.Run(wait.NeverStop)
-	go cd.cmInformer.Run(wait.NeverStop)
-	go cd.scrtInformer.Run(wait.NeverStop)
-	go cd.nsInformer.Run(wait.NeverStop)
+	}, false))
+	go wait.Until(func() {
+		cd.enqueue(cd.checkClusters)
+	}, 30*time.Second, ctx.Done())
+	go cd.work()
 
 	select {
 	case <-ctx.Done():
 		// Work is done.
+		cd.queue.ShutDown()
 	}
 }
 
+// startInformers starts the informers and waits until their caches are
+// synced. Starting them again only waits for the already started ones.
+func (cd *ConfigurationDistributor) startInformers(ctx context.Context) error {
+	cd.ruleFactory.Start(ctx.Done())
+	cd.factory.Start(ctx.Done())
+	for _, synced := range cd.factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return errors.New("informer caches not synced")
+		}
+	}
+	for _, synced := range cd.ruleFactory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return errors.New("rule informer cache not synced")
+		}
+	}
+	return nil
+}
+
+// handling is a queued call of an event handler or a cluster check.
+type handling struct {
+	handle func()
+}
+
+// enqueue adds the handling function to the work queue.
+func (cd *ConfigurationDistributor) enqueue(handle func()) {
+	cd.queue.Add(&handling{handle: handle})
+}
+
+// work processes the work queue until it is shut down. There is only one
+// worker, so all handlings are performed one after another.
+func (cd *ConfigurationDistributor) work() {
+	for {
+		h, shutdown := cd.queue.Get()
+		if shutdown {
+			return
+		}
+		h.handle()
+		cd.queue.Done(h)
+	}
+}
+
+// serialized returns the handlers queueing their calls in the work queue.
+// The handlers of the different informers and the cluster checks are
+// called in own goroutines, but all of them read and set the rule. Without
+// initial the adds of the objects already in the cache are skipped, as the
+// first distribution covers them.
+func (cd *ConfigurationDistributor) serialized(handlers cache.ResourceEventHandlerFuncs, initial bool) cache.ResourceEventHandlerDetailedFuncs {
+	serialized := cache.ResourceEventHandlerDetailedFuncs{}
+	if handlers.AddFunc != nil {
+		serialized.AddFunc = func(obj interface{}, isInInitialList bool) {
+			if isInInitialList && !initial {
+				return
+			}
+			cd.enqueue(func() { handlers.AddFunc(obj) })
+		}
+	}
+	if handlers.UpdateFunc != nil {
+		serialized.UpdateFunc = func(oldobj, newobj interface{}) {
+			cd.enqueue(func() { handlers.UpdateFunc(oldobj, newobj) })
+		}
+	}
+	if handlers.DeleteFunc != nil {
+		serialized.DeleteFunc = func(obj interface{}) {
+			cd.enqueue(func() { handlers.DeleteFunc(obj) })
+		}
+	}
+	return serialized
+}
+
 // addRuleHandler handles the adding of rules.
 func (cd *ConfigurationDistributor) addRuleHandler(obj interface{}) {
 	rule := obj.(*codisv1alpha1.ConfigurationDistributionRule)
 	if rule.GetNamespace() != cd.namespace || rule.GetName() != cd.rulename {
 		return
 	}
-	log.Printf("adding rule '%s' in namespace '%s' ...", rule.GetName(), rule.GetNamespace())
-	cd.rule = rule
+	cd.log.Info("adding rule")
+	cd.setRule(rule)
 	cd.distributeAll()
+	cd.updateStatus()
 }
 
 // updateRuleHandler handles the updating of rules.
@@ -131,8 +275,13 @@ func (cd *ConfigurationDistributor) updateRuleHandler(oldobj, newobj interface{}
 	if oldrule.GetResourceVersion() == newrule.GetResourceVersion() {
 		return
 	}
-	log.Printf("updating rule '%s' in namespace '%s' ...", newrule.GetName(), newrule.GetNamespace())
-	cd.rule = newrule
+	if reflect.DeepEqual(oldrule.Spec, newrule.Spec) {
+		// Only the status changed.
+		cd.setRule(newrule)
+		return
+	}
+	cd.log.Info("updating rule")
+	cd.setRule(newrule)
 	cd.distributeAll()
 }
 
@@ -142,21 +291,34 @@ func (cd *ConfigurationDistributor) deleteRuleHandler(obj interface{}) {
 	if rule.GetNamespace() != cd.namespace || rule.GetName() != cd.rulename {
 		return
 	}
-	log.Printf("deleting rule '%s' in namespace '%s' ...", rule.GetName(), rule.GetNamespace())
-	cd.rule = nil
+	cd.log.Info("deleting rule")
+	cd.setRule(nil)
 }
 
 // distributeAll copies all config maps and secrets to the namespaces of the rule.
 func (cd *ConfigurationDistributor) distributeAll() {
-	distributeAllOf := func(resource string) error {
-		// TODO
-		return nil
-	}
-	if err := distributeAllOf("configmap"); err != nil {
-		log.Printf("cannot copy all configmaps: %v", err)
+	targets := cd.targets()
+	if !cd.aggregates() {
+		cd.setConflicts(nil)
+	}
+	if cd.distributes("configmap") {
+		for _, obj := range cd.cmInformer.GetStore().List() {
+			cm := obj.(*corev1.ConfigMap)
+			if cd.sourceNamespace(cm.GetNamespace()) && cd.selects("configmap", cm) && !cd.aggregated(cm) {
+				cd.applyConfigMap(cm, targets)
+			}
+		}
+		if cd.aggregates() {
+			cd.applyAggregate(targets)
+		}
 	}
-	if err := distributeAllOf("secret"); err != nil {
-		log.Printf("cannot copy all configmaps: %v", err)
+	if cd.distributes("secret") {
+		for _, obj := range cd.scrtInformer.GetStore().List() {
+			scrt := obj.(*corev1.Secret)
+			if cd.sourceNamespace(scrt.GetNamespace()) && cd.selects("secret", scrt) {
+				cd.applySecret(scrt, targets)
+			}
+		}
 	}
 }
 
@@ -165,19 +327,21 @@ func (cd *ConfigurationDistributor) addConfigMapHandler(obj interface{}) {
 	if cd.rule == nil {
 		return
 	}
-	if cd.rule.Spec.Mode != "configmap" && cd.rule.Spec.Mode != "both" {
+	cm := obj.(*corev1.ConfigMap)
+	if cd.encryptionKey(cm) {
+		cd.reencrypt(cm.GetNamespace())
+	}
+	if !cd.distributes("configmap") || !cd.sourceNamespace(cm.GetNamespace()) {
 		return
 	}
-	cm := obj.(*corev1.ConfigMap)
-	if cm.GetNamespace() != cd.namespace {
+	if !cd.selects("configmap", cm) {
 		return
 	}
-	if cd.rule.Spec.Selector != "" {
-		if cm.GetLabels()["rule"] != cd.rule.Spec.Selector {
-			return
-		}
+	if cd.aggregated(cm) {
+		cd.applyAggregate(cd.targets())
+		return
 	}
-	cd.applyConfigMap(cm, true)
+	cd.applyConfigMap(cm, cd.targets())
 }
 
 // updateConfigMapHandler handles the updating of ConfigMaps.
@@ -185,67 +349,122 @@ func (cd *ConfigurationDistributor) updateConfigMapHandler(oldobj, newobj interf
 	if cd.rule == nil {
 		return
 	}
-	if cd.rule.Spec.Mode != "configmap" && cd.rule.Spec.Mode != "both" {
-		return
-	}
 	oldcm := oldobj.(*corev1.ConfigMap)
 	newcm := newobj.(*corev1.ConfigMap)
-	if newcm.GetNamespace() != cd.namespace || oldcm.GetResourceVersion() == newcm.GetResourceVersion() {
+	if oldcm.GetResourceVersion() == newcm.GetResourceVersion() {
 		return
 	}
-	if cd.rule.Spec.Selector != "" {
-		if newcm.GetLabels()["rule"] != cd.rule.Spec.Selector {
-			return
+	if cd.encryptionKey(newcm) {
+		cd.reencrypt(newcm.GetNamespace())
+	}
+	if !cd.sourceNamespace(newcm.GetNamespace()) {
+		cd.detectConfigMapDrift(newcm)
+		return
+	}
+	if !cd.distributes("configmap") {
+		return
+	}
+	if cd.aggregated(newcm) {
+		if cd.selects("configmap", oldcm) || cd.selects("configmap", newcm) {
+			cd.applyAggregate(cd.targets())
 		}
+		return
+	}
+	if !cd.selects("configmap", newcm) {
+		return
 	}
-	cd.applyConfigMap(newcm, false)
+	cd.applyConfigMap(newcm, cd.targets())
 }
 
-// applyConfigMap applies the ConfigMap to the namespaces configured in the distributor.
-func (cd *ConfigurationDistributor) applyConfigMap(in *corev1.ConfigMap, create bool) {
-	log.Printf("applying 'configmap/%s' ...", in.GetName())
-	for _, namespace := range cd.rule.Spec.Namespaces {
-		cmInf := cd.client.CoreV1().ConfigMaps(namespace)
-		out := in.DeepCopy()
-		out.SetNamespace(namespace)
-		out.SetResourceVersion("")
-		out.SetUID("")
-
-		var err error
-		if create {
-			_, err = cmInf.Create(out)
-		} else {
-			_, err = cmInf.Update(out)
+// deleteConfigMapHandler handles the deleting of ConfigMaps. Their copies
+// are only deleted if the deletion policy of the rule says so.
+func (cd *ConfigurationDistributor) deleteConfigMapHandler(obj interface{}) {
+	if cd.rule == nil || !cd.distributes("configmap") {
+		return
+	}
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok || !cd.sourceNamespace(cm.GetNamespace()) {
+		return
+	}
+	if cd.aggregated(cm) {
+		if cd.selects("configmap", cm) {
+			cd.applyAggregate(cd.targets())
 		}
+		return
+	}
+	if !cd.selects("configmap", cm) || cd.rule.Spec.DeletionPolicy != deletionPolicyDelete {
+		return
+	}
+	if projection, ok := cd.projectionFor("configmap", cm.GetName()); ok {
+		cd.removeCopies(cm, "secret", "secrets", codisv1alpha1.ProjectedName(projection), cd.targets())
+		return
+	}
+	cd.removeCopies(cm, "configmap", "configmaps", cm.GetName(), cd.targets())
+}
+
+// applyConfigMap applies the ConfigMap to the given targets.
+func (cd *ConfigurationDistributor) applyConfigMap(in *corev1.ConfigMap, targets []target) {
+	cd.log.Info("applying source", "kind", "configmap", "name", in.GetName(), "dryRun", cd.dryRun())
+	defer cd.beginReconcile("configmap")()
+	ctx, span := cd.startReconcileSpan("configmap", in.GetName())
+	if err := cd.checkSourceAccess(ctx, "configmap", in.GetNamespace()); err != nil {
+		cd.refuse(span, in, "configmap", in.GetName(), err)
+		return
+	}
+	if projection, ok := cd.projectionFor("configmap", in.GetName()); ok {
+		cd.applyConfigMapProjection(ctx, span, in, projection, targets)
+		return
+	}
+	o := &outcome{}
+	for _, t := range targets {
+		tctx, tspan := cd.startTargetSpan(ctx, "configmap", in.GetName(), t)
+		var action string
+		out, err := cd.configMapFor(in, t)
+		if err == nil {
+			action, err = cd.writeConfigMap(tctx, t, out)
+			cd.reportTarget(t, err)
+		}
+		cd.reportApply(tspan, in, "configmap", in.GetName(), t, action, err)
+		o.add("configmap", in.GetName(), t, action, err)
+	}
+	cd.finishReconcile(span, in, "configmap", in.GetName(), o)
+}
+
+// configMapFor returns the copy of the ConfigMap for the given target.
+func (cd *ConfigurationDistributor) configMapFor(in *corev1.ConfigMap, t target) (*corev1.ConfigMap, error) {
+	out := in.DeepCopy()
+	out.ObjectMeta = sanitizeMeta(in.ObjectMeta, t.namespace, cd.rule.Spec.Metadata)
+	if cd.rule.Spec.Template {
+		data, err := renderData(in.Data, cd.templateDataFor(t))
 		if err != nil {
-			log.Printf(
-				"cannot apply 'configmap/%s' to namespace '%s': %v",
-				in.GetName(),
-				namespace,
-				err,
-			)
+			return nil, fmt.Errorf("cannot render data: %v", err)
 		}
+		out.Data = data
 	}
+	data, err := patchData(out.Data, cd.overridesFor(t))
+	if err != nil {
+		return nil, fmt.Errorf("cannot patch data: %v", err)
+	}
+	out.Data = data
+	return out, nil
 }
 
 // addSecretHandler handles the adding of Secrets.
 func (cd *ConfigurationDistributor) addSecretHandler(obj interface{}) {
-	if cd.rule == nil {
-		return
-	}
-	if cd.rule.Spec.Mode != "secret" && cd.rule.Spec.Mode != "both" {
+	if cd.rule == nil || !cd.distributes("secret") {
 		return
 	}
 	scrt := obj.(*corev1.Secret)
-	if scrt.GetNamespace() != cd.namespace {
+	if !cd.sourceNamespace(scrt.GetNamespace()) {
 		return
 	}
-	if cd.rule.Spec.Selector != "" {
-		if scrt.GetLabels()["rule"] != cd.rule.Spec.Selector {
-			return
-		}
+	if !cd.selects("secret", scrt) {
+		return
 	}
-	cd.applySecret(scrt, true)
+	cd.applySecret(scrt, cd.targets())
 }
 
 // updateSecretHandler handles the updating of Secrets.
@@ -253,47 +472,291 @@ func (cd *ConfigurationDistributor) updateSecretHandler(oldobj, newobj interface
 	if cd.rule == nil {
 		return
 	}
-	if cd.rule.Spec.Mode != "secret" && cd.rule.Spec.Mode != "both" {
-		return
-	}
 	oldscrt := oldobj.(*corev1.Secret)
 	newscrt := newobj.(*corev1.Secret)
-	if newscrt.GetNamespace() != cd.namespace || oldscrt.GetResourceVersion() == newscrt.GetResourceVersion() {
+	if oldscrt.GetResourceVersion() == newscrt.GetResourceVersion() {
 		return
 	}
-	if cd.rule.Spec.Selector != "" {
-		if newscrt.GetLabels()["rule"] != cd.rule.Spec.Selector {
-			return
+	if !cd.sourceNamespace(newscrt.GetNamespace()) {
+		cd.detectSecretDrift(newscrt)
+		return
+	}
+	if !cd.distributes("secret") {
+		return
+	}
+	if !cd.selects("secret", newscrt) {
+		return
+	}
+	cd.applySecret(newscrt, cd.targets())
+}
+
+// deleteSecretHandler handles the deleting of Secrets. Their copies
+// are only deleted if the deletion policy of the rule says so.
+func (cd *ConfigurationDistributor) deleteSecretHandler(obj interface{}) {
+	if cd.rule == nil || !cd.distributes("secret") {
+		return
+	}
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	scrt, ok := obj.(*corev1.Secret)
+	if !ok || !cd.sourceNamespace(scrt.GetNamespace()) {
+		return
+	}
+	if !cd.selects("secret", scrt) || cd.rule.Spec.DeletionPolicy != deletionPolicyDelete {
+		return
+	}
+	if projection, ok := cd.projectionFor("secret", scrt.GetName()); ok {
+		cd.removeCopies(scrt, "configmap", "configmaps", codisv1alpha1.ProjectedName(projection), cd.targets())
+		return
+	}
+	cd.removeCopies(scrt, "secret", "secrets", scrt.GetName(), cd.targets())
+}
+
+// applySecret applies the Secret to the given targets.
+func (cd *ConfigurationDistributor) applySecret(in *corev1.Secret, targets []target) {
+	cd.log.Info("applying source", "kind", "secret", "name", in.GetName(), "dryRun", cd.dryRun())
+	defer cd.beginReconcile("secret")()
+	ctx, span := cd.startReconcileSpan("secret", in.GetName())
+	if err := cd.checkSourceAccess(ctx, "secret", in.GetNamespace()); err != nil {
+		cd.refuse(span, in, "secret", in.GetName(), err)
+		return
+	}
+	scrtType, err := cd.secretTypeFor(in)
+	if err != nil {
+		cd.refuse(span, in, "secret", in.GetName(), err)
+		return
+	}
+	if projection, ok := cd.projectionFor("secret", in.GetName()); ok {
+		cd.applySecretProjection(ctx, span, in, projection, targets)
+		return
+	}
+	o := &outcome{}
+	for _, t := range targets {
+		tctx, tspan := cd.startTargetSpan(ctx, "secret", in.GetName(), t)
+		var action string
+		out, err := cd.secretFor(in, t, scrtType)
+		if err == nil {
+			action, err = cd.writeSecret(tctx, t, out)
+			cd.reportTarget(t, err)
 		}
+		cd.reportApply(tspan, in, "secret", in.GetName(), t, action, err)
+		o.add("secret", in.GetName(), t, action, err)
 	}
-	cd.applySecret(newscrt, false)
+	cd.finishReconcile(span, in, "secret", in.GetName(), o)
 }
 
-// applySecret applies the Secret to the namespaces configured in the distributor.
-func (cd *ConfigurationDistributor) applySecret(in *corev1.Secret, create bool) {
-	log.Printf("applying 'secret/%s' ...", in.GetName())
-	for _, namespace := range cd.rule.Spec.Namespaces {
-		scrtInf := cd.client.CoreV1().Secrets(namespace)
-		out := in.DeepCopy()
-		out.SetNamespace(namespace)
-		out.SetResourceVersion("")
-		out.SetUID("")
-
-		var err error
-		if create {
-			_, err = scrtInf.Create(out)
-		} else {
-			_, err = scrtInf.Update(out)
+// secretFor returns the copy of the Secret with the given type for the
+// target, encrypted if the rule says so.
+func (cd *ConfigurationDistributor) secretFor(in *corev1.Secret, t target, scrtType corev1.SecretType) (*corev1.Secret, error) {
+	out := in.DeepCopy()
+	out.ObjectMeta = sanitizeMeta(in.ObjectMeta, t.namespace, cd.rule.Spec.Metadata)
+	out.Type = scrtType
+	data, err := patchBinaryData(out.Data, cd.overridesFor(t))
+	if err != nil {
+		return nil, fmt.Errorf("cannot patch data: %v", err)
+	}
+	out.Data = data
+	if cd.encrypts(t) {
+		if err := cd.encryptSecret(out, t); err != nil {
+			return nil, fmt.Errorf("cannot encrypt data: %v", err)
+		}
+	}
+	return out, nil
+}
+
+// removeCopies deletes the copies of a deleted source in the given targets.
+func (cd *ConfigurationDistributor) removeCopies(source runtime.Object, kind, resource, name string, targets []target) {
+	cd.log.Info("removing copies", "kind", kind, "name", name, "dryRun", cd.dryRun())
+	defer cd.beginReconcile(kind)()
+	ctx, span := cd.startReconcileSpan(kind, name)
+	o := &outcome{}
+	for _, t := range targets {
+		tctx, tspan := cd.startTargetSpan(ctx, kind, name, t)
+		action, err := cd.deleteCopy(tctx, t, resource, name)
+		cd.reportTarget(t, err)
+		cd.reportApply(tspan, source, kind, name, t, action, err)
+		o.add(kind, name, t, action, err)
+	}
+	cd.finishReconcile(span, source, kind, name, o)
+}
+
+// secretTypeFor returns the type of the distributed copies of the Secret
+// based on the conversions of the rule. Service account tokens are bound
+// to their namespace and so must not be distributed.
+func (cd *ConfigurationDistributor) secretTypeFor(in *corev1.Secret) (corev1.SecretType, error) {
+	scrtType := in.Type
+	if scrtType == "" {
+		scrtType = corev1.SecretTypeOpaque
+	}
+	if scrtType == corev1.SecretTypeServiceAccountToken {
+		return "", fmt.Errorf("secrets of type '%s' cannot be distributed", scrtType)
+	}
+	if to, ok := cd.rule.Spec.SecretTypes[string(scrtType)]; ok {
+		scrtType = corev1.SecretType(to)
+	}
+	if scrtType == corev1.SecretTypeServiceAccountToken {
+		return "", fmt.Errorf("secrets cannot be converted into type '%s'", scrtType)
+	}
+	return scrtType, nil
+}
+
+// reportApply reports the outcome of applying a copy of a source to
+// a target to the metrics, the log, the Events, and the trace.
+func (cd *ConfigurationDistributor) reportApply(span trace.Span, source runtime.Object, kind, name string, t target, action string, err error) {
+	cd.reportCopy(kind, t, action, err)
+	cd.endTargetSpan(span, action, err)
+	if err != nil {
+		cd.targetLog(kind, name, t).Error(err, "cannot apply copy", "dryRun", cd.dryRun())
+		cd.recordFailed(source, kind, name, t, err)
+		if cd.changes != nil {
+			cd.changes.fail(fmt.Errorf("cannot apply %s '%s' to '%s': %v", kind, name, t, err))
+		}
+		return
+	}
+	if cd.dryRun() && action != actionUnchanged {
+		cd.targetLog(kind, name, t).Info("planned copy", "action", action, "dryRun", true)
+		return
+	}
+	cd.targetLog(kind, name, t).V(1).Info("applied copy", "action", action)
+}
+
+// refuse reports a source which cannot be distributed at all.
+func (cd *ConfigurationDistributor) refuse(span trace.Span, source runtime.Object, kind, name string, err error) {
+	cd.log.Error(err, "cannot apply source", "kind", kind, "name", name)
+	cd.recordRefused(source, kind, name, err)
+	if cd.changes != nil {
+		cd.changes.fail(fmt.Errorf("cannot apply %s '%s': %v", kind, name, err))
+	}
+	cd.endReconcileSpan(span, &outcome{}, err)
+}
+
+// finishReconcile records the outcome of a reconcile of a source.
+func (cd *ConfigurationDistributor) finishReconcile(span trace.Span, source runtime.Object, kind, name string, o *outcome) {
+	cd.planOperations(kind, name, o.operations)
+	cd.recordDistributed(source, kind, name, o)
+	cd.endReconcileSpan(span, o, nil)
+}
+
+// context returns the context of the running distributor. API calls
+// outside of reconciles use it.
+func (cd *ConfigurationDistributor) context() context.Context {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	if cd.ctx == nil {
+		return context.Background()
+	}
+	return cd.ctx
+}
+
+// distributes checks if the rule distributes sources of the kind.
+func (cd *ConfigurationDistributor) distributes(kind string) bool {
+	return cd.rule.Spec.Mode == kind || cd.rule.Spec.Mode == "both"
+}
+
+// selects checks if the source of the kind is referenced by the rule or
+// if its labels match the selector of the rule. The kubeconfig Secrets of
+// the remote clusters are never selected.
+func (cd *ConfigurationDistributor) selects(kind string, source metav1.Object) bool {
+	if kind == "secret" && cd.kubeconfigSecret(source) {
+		return false
+	}
+	for _, ref := range cd.rule.Spec.Sources {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = cd.namespace
 		}
+		if strings.EqualFold(ref.Kind, kind) && ref.Name == source.GetName() && namespace == source.GetNamespace() {
+			return true
+		}
+	}
+	return cd.selector.Matches(labels.Set(source.GetLabels()))
+}
+
+// setRule sets the rule with its defaults set, so rules created without
+// the defaulting webhook behave the same, and prepares its selectors.
+// Invalid selectors select nothing.
+func (cd *ConfigurationDistributor) setRule(rule *codisv1alpha1.ConfigurationDistributionRule) {
+	if rule == nil {
+		cd.rule = nil
+		return
+	}
+	rule = rule.DeepCopyObject().(*codisv1alpha1.ConfigurationDistributionRule)
+	codisv1alpha1.SetDefaults(rule)
+	selector, nsSelectors, err := selectorsOf(rule)
+	if err != nil {
+		cd.log.Error(err, "invalid selectors of rule")
+		selector, nsSelectors = labels.Nothing(), make([]labels.Selector, len(rule.Spec.Overrides))
+		for i := range nsSelectors {
+			nsSelectors[i] = labels.Nothing()
+		}
+	}
+	cd.selector = selector
+	cd.nsSelectors = nsSelectors
+	cd.rule = rule
+}
+
+// selectorsOf returns the source selector and the namespace selectors of
+// the overrides of the rule. Selectors which cannot be expressed in the
+// v1alpha1 rule are taken from the v1beta1 version.
+func selectorsOf(rule *codisv1alpha1.ConfigurationDistributionRule) (labels.Selector, []labels.Selector, error) {
+	beta, err := codisv1beta1.ConvertFromV1alpha1(rule)
+	if err != nil {
+		return nil, nil, err
+	}
+	asSelector := func(ls *metav1.LabelSelector) (labels.Selector, error) {
+		if ls == nil {
+			return labels.Everything(), nil
+		}
+		return metav1.LabelSelectorAsSelector(ls)
+	}
+	selector, err := asSelector(beta.Spec.Source.Selector)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid source selector: %v", err)
+	}
+	if beta.Spec.Source.Selector == nil && len(beta.Spec.Source.References) > 0 {
+		// Only the referenced sources are distributed.
+		selector = labels.Nothing()
+	}
+	var nsSelectors []labels.Selector
+	for i, override := range beta.Spec.Target.Overrides {
+		nsSelector, err := asSelector(override.NamespaceSelector)
 		if err != nil {
-			log.Printf(
-				"cannot apply 'secret/%s' to namespace '%s': %v",
-				in.GetName(),
-				namespace,
-				err,
-			)
+			return nil, nil, fmt.Errorf("invalid namespace selector of override %d: %v", i, err)
+		}
+		nsSelectors = append(nsSelectors, nsSelector)
+	}
+	return selector, nsSelectors, nil
+}
+
+// sourceByName returns the source with the name out of the store. With
+// several source namespaces the first one containing it wins.
+func (cd *ConfigurationDistributor) sourceByName(store cache.Store, name string) (interface{}, bool) {
+	for _, namespace := range codisv1alpha1.SourceNamespaces(cd.rule) {
+		obj, exists, err := store.GetByKey(namespace + "/" + name)
+		if err == nil && exists {
+			return obj, true
+		}
+	}
+	return nil, false
+}
+
+// localTarget returns the target for the namespace if it is one of the
+// local namespaces of the rule. Like in targets source namespaces are
+// never targets.
+func (cd *ConfigurationDistributor) localTarget(namespace string) (target, bool) {
+	if cd.sourceNamespace(namespace) {
+		return target{}, false
+	}
+	for _, ns := range cd.rule.Spec.Namespaces {
+		if ns == namespace {
+			return target{
+				client:    cd.client,
+				namespace: namespace,
+			}, true
 		}
 	}
+	return target{}, false
 }
 
 // addNamespaceHandler handles the adding of Namespaces.
@@ -315,13 +778,34 @@ func (cd *ConfigurationDistributor) addNamespaceHandler(obj interface{}) {
 // applyMatchingConfigMaps applies the matching ConfigMaps in own Namespace to
 // the given Namespace.
 func (cd *ConfigurationDistributor) applyMatchingConfigMaps(namespace string) {
-	// TODO Search ConfigMaps and copy those.
+	t, ok := cd.localTarget(namespace)
+	if !ok || !cd.distributes("configmap") {
+		return
+	}
+	for _, obj := range cd.cmInformer.GetStore().List() {
+		cm := obj.(*corev1.ConfigMap)
+		if cd.sourceNamespace(cm.GetNamespace()) && cd.selects("configmap", cm) && !cd.aggregated(cm) {
+			cd.applyConfigMap(cm, []target{t})
+		}
+	}
+	if cd.aggregates() {
+		cd.applyAggregate([]target{t})
+	}
 }
 
 // applyMatchingSecrets applies the matching Secrets in own Namespace to
 // the given Namespace.
 func (cd *ConfigurationDistributor) applyMatchingSecrets(namespace string) {
-	// TODO Search Secrets and copy those.
+	t, ok := cd.localTarget(namespace)
+	if !ok || !cd.distributes("secret") {
+		return
+	}
+	for _, obj := range cd.scrtInformer.GetStore().List() {
+		scrt := obj.(*corev1.Secret)
+		if cd.sourceNamespace(scrt.GetNamespace()) && cd.selects("secret", scrt) {
+			cd.applySecret(scrt, []target{t})
+		}
+	}
 }
 
 // EOF
@@ -0,0 +1,91 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr/testr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestDeletionPolicy tests that the copies of deleted sources are only
+// removed with the deletion policy "delete" and that the plan contains
+// their deletion.
+func TestDeletionPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		removed bool
+		planned bool
+	}{
+		{name: "retain", policy: deletionPolicyRetain},
+		{name: "delete", policy: deletionPolicyDelete, removed: true, planned: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "app",
+					Namespace: "default",
+					Labels:    map[string]string{"rule": "test"},
+				},
+				Data: map[string][]byte{"password": []byte("secret")},
+			}
+			rule := &codisv1alpha1.ConfigurationDistributionRule{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: codisv1alpha1.ConfigurationDistributionRuleSpec{
+					Mode:           "secret",
+					Selector:       "test",
+					Namespaces:     []string{"apps"},
+					DeletionPolicy: test.policy,
+				},
+			}
+			codisv1alpha1.SetDefaults(rule)
+			client := fake.NewSimpleClientset(source)
+			cd := newTestDistributor(t, client, rule)
+			cd.applySecret(source, cd.targets())
+			getSecret(t, cd, "apps", "app")
+
+			ctx := context.Background()
+			if err := client.CoreV1().Secrets("default").Delete(ctx, "app", metav1.DeleteOptions{}); err != nil {
+				t.Fatalf("cannot delete source: %v", err)
+			}
+			changes, err := Plan(ctx, client, rule, testChecksumKey, testr.New(t))
+			if err != nil {
+				t.Fatalf("cannot plan rule: %v", err)
+			}
+			planned := len(changes) == 1 && changes[0].Action == actionDelete && changes[0].Name == "app"
+			if planned != test.planned {
+				t.Errorf("got changes %v, want planned deletion %v", changes, test.planned)
+			}
+
+			cd.deleteSecretHandler(source)
+			_, err = client.CoreV1().Secrets("apps").Get(ctx, "app", metav1.GetOptions{})
+			if removed := errors.IsNotFound(err); removed != test.removed {
+				t.Errorf("got copy removed %v, want %v", removed, test.removed)
+			}
+		})
+	}
+}
+
+// EOF
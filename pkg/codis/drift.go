@@ -0,0 +1,143 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+//--------------------
+// DRIFT DETECTION
+//--------------------
+
+// detectConfigMapDrift checks if the changed ConfigMap is a copy in a
+// local target namespace differing from the wanted one.
+func (cd *ConfigurationDistributor) detectConfigMapDrift(cm *corev1.ConfigMap) {
+	t, ok := cd.localTarget(cm.GetNamespace())
+	if !ok {
+		return
+	}
+	out, ok := cd.wantedConfigMap(cm.GetName(), t)
+	if !ok || !changedConfigMap(cm, out) {
+		return
+	}
+	cd.reportDrift("configmap", cm.GetName(), t)
+}
+
+// detectSecretDrift checks if the changed Secret is a copy in a local
+// target namespace differing from the wanted one.
+func (cd *ConfigurationDistributor) detectSecretDrift(scrt *corev1.Secret) {
+	t, ok := cd.localTarget(scrt.GetNamespace())
+	if !ok {
+		return
+	}
+	out, ok := cd.wantedSecret(scrt.GetName(), t)
+	if !ok || !changedSecret(scrt, out) {
+		return
+	}
+	cd.reportDrift("secret", scrt.GetName(), t)
+}
+
+// wantedConfigMap returns the wanted copy of the ConfigMap with the name
+// in the target. It is the aggregate, the projection of a Secret, or the
+// copy of a ConfigMap. Names not distributed by the rule return false.
+func (cd *ConfigurationDistributor) wantedConfigMap(name string, t target) (*corev1.ConfigMap, bool) {
+	if projection, ok := cd.projectionInto("configmap", name); ok {
+		if !cd.distributes("secret") {
+			return nil, false
+		}
+		obj, ok := cd.sourceByName(cd.scrtInformer.GetStore(), projection.Name)
+		if !ok || !cd.selects("secret", obj.(*corev1.Secret)) {
+			return nil, false
+		}
+		proj, err := projectSecret(obj.(*corev1.Secret), projection)
+		if err != nil {
+			return nil, false
+		}
+		return cd.configMapOrNot(proj, t)
+	}
+	if !cd.distributes("configmap") {
+		return nil, false
+	}
+	if cd.aggregates() {
+		if name != cd.rule.Spec.Aggregate.Name {
+			return nil, false
+		}
+		ins := cd.aggregateSources(cd.context())
+		if len(ins) == 0 {
+			return nil, false
+		}
+		agg, _ := cd.aggregateOf(ins)
+		return cd.configMapOrNot(agg, t)
+	}
+	obj, ok := cd.sourceByName(cd.cmInformer.GetStore(), name)
+	if !ok {
+		return nil, false
+	}
+	in := obj.(*corev1.ConfigMap)
+	if _, projected := cd.projectionFor("configmap", name); projected || !cd.selects("configmap", in) {
+		return nil, false
+	}
+	return cd.configMapOrNot(in, t)
+}
+
+// wantedSecret returns the wanted copy of the Secret with the name in the
+// target. It is the projection of a ConfigMap or the copy of a Secret.
+// Names not distributed by the rule return false.
+func (cd *ConfigurationDistributor) wantedSecret(name string, t target) (*corev1.Secret, bool) {
+	if projection, ok := cd.projectionInto("secret", name); ok {
+		if !cd.distributes("configmap") {
+			return nil, false
+		}
+		obj, ok := cd.sourceByName(cd.cmInformer.GetStore(), projection.Name)
+		if !ok || !cd.selects("configmap", obj.(*corev1.ConfigMap)) {
+			return nil, false
+		}
+		proj, err := projectConfigMap(obj.(*corev1.ConfigMap), projection)
+		if err != nil {
+			return nil, false
+		}
+		return cd.secretOrNot(proj, t, corev1.SecretTypeOpaque)
+	}
+	if !cd.distributes("secret") {
+		return nil, false
+	}
+	obj, ok := cd.sourceByName(cd.scrtInformer.GetStore(), name)
+	if !ok {
+		return nil, false
+	}
+	in := obj.(*corev1.Secret)
+	if _, projected := cd.projectionFor("secret", name); projected || !cd.selects("secret", in) {
+		return nil, false
+	}
+	scrtType, err := cd.secretTypeFor(in)
+	if err != nil {
+		return nil, false
+	}
+	return cd.secretOrNot(in, t, scrtType)
+}
+
+// configMapOrNot returns the copy of the ConfigMap for the target if it
+// can be created.
+func (cd *ConfigurationDistributor) configMapOrNot(in *corev1.ConfigMap, t target) (*corev1.ConfigMap, bool) {
+	out, err := cd.configMapFor(in, t)
+	return out, err == nil
+}
+
+// secretOrNot returns the copy of the Secret for the target if it can
+// be created.
+func (cd *ConfigurationDistributor) secretOrNot(in *corev1.Secret, t target, scrtType corev1.SecretType) (*corev1.Secret, bool) {
+	out, err := cd.secretFor(in, t, scrtType)
+	return out, err == nil
+}
+
+// EOF
@@ -0,0 +1,97 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestDriftDetection tests that changed copies in target namespaces are
+// counted as drifts but not reverted.
+func TestDriftDetection(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		copyName  string
+		data      map[string]string
+		drifts    float64
+	}{
+		{name: "unchanged", namespace: "apps", copyName: "app", data: map[string]string{"level": "debug"}},
+		{name: "changed", namespace: "apps", copyName: "app", data: map[string]string{"level": "info"}, drifts: 1},
+		{name: "projected", namespace: "apps", copyName: "credentials", data: map[string]string{"user": "admin"}, drifts: 1},
+		{name: "not distributed", namespace: "apps", copyName: "other", data: map[string]string{"level": "info"}},
+		{name: "no target", namespace: "tools", copyName: "app", data: map[string]string{"level": "info"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			labels := map[string]string{"rule": "test"}
+			client := fake.NewSimpleClientset(
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", Labels: labels},
+					Data:       map[string]string{"level": "debug"},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default", Labels: labels},
+					Data:       map[string][]byte{"user": []byte("codis"), "password": []byte("secret")},
+				},
+			)
+			cd := newTestDistributor(t, client, &codisv1alpha1.ConfigurationDistributionRule{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: codisv1alpha1.ConfigurationDistributionRuleSpec{
+					Mode:       "both",
+					Selector:   "test",
+					Namespaces: []string{"apps"},
+					Projections: []codisv1alpha1.Projection{
+						{Kind: "Secret", Name: "credentials", Keys: []string{"user"}},
+					},
+				},
+			})
+			changed := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: test.copyName, Namespace: test.namespace, Labels: labels, ResourceVersion: "2"},
+				Data:       test.data,
+			}
+			ctx := context.Background()
+			if _, err := client.CoreV1().ConfigMaps(test.namespace).Create(ctx, changed, metav1.CreateOptions{}); err != nil {
+				t.Fatalf("cannot create copy: %v", err)
+			}
+			old := changed.DeepCopy()
+			old.SetResourceVersion("1")
+			cd.updateConfigMapHandler(old, changed)
+
+			drifts := testutil.ToFloat64(cd.metrics.driftsDetected.WithLabelValues("test", "configmap", test.namespace))
+			if drifts != test.drifts {
+				t.Errorf("got %v drifts, want %v", drifts, test.drifts)
+			}
+			cm, err := client.CoreV1().ConfigMaps(test.namespace).Get(ctx, test.copyName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("cannot get copy: %v", err)
+			}
+			if !equalContent(cm.Data, test.data) {
+				t.Errorf("got copy data %v, want unreverted %v", cm.Data, test.data)
+			}
+		})
+	}
+}
+
+// EOF
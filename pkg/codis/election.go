@@ -0,0 +1,71 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+//--------------------
+// LEADER ELECTION
+//--------------------
+
+// RunElected executes the configuration distributor only while it is the
+// elected leader of all distributors using the Lease with the name in the
+// namespace of the rule. The identity has to be unique per distributor,
+// e.g. the name of its pod. The informers are started before the election,
+// so standby distributors are ready and take over with synced caches. It
+// returns when the leadership is lost.
+func (cd *ConfigurationDistributor) RunElected(ctx context.Context, leaseName, identity string) error {
+	if err := cd.startInformers(ctx); err != nil {
+		return fmt.Errorf("cannot start informers: %v", err)
+	}
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      leaseName,
+			Namespace: cd.namespace,
+		},
+		Client: cd.client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
+	}
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		ReleaseOnCancel: true,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				cd.log.Info("started leading", "identity", identity)
+				cd.Run(ctx)
+			},
+			OnStoppedLeading: func() {
+				cd.log.Info("stopped leading", "identity", identity)
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot create leader elector: %v", err)
+	}
+	elector.Run(ctx)
+	return nil
+}
+
+// EOF
@@ -0,0 +1,272 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+const (
+	// annotationEncryption marks encrypted copies with the used scheme.
+	annotationEncryption = "k8s.tideland.dev/encryption"
+
+	// annotationChecksum contains the keyed checksum of the plaintext
+	// of encrypted copies.
+	annotationChecksum = "k8s.tideland.dev/checksum"
+
+	// checksumKeySize is the size of the random checksum key used if
+	// none is configured.
+	checksumKeySize = 32
+
+	// encryptionScheme describes the envelope of each encrypted value.
+	encryptionScheme = "rsa-oaep-sha256+aes-256-gcm"
+)
+
+//--------------------
+// ENCRYPTION
+//--------------------
+
+// encrypts checks if the rule encrypts Secret copies in the target.
+func (cd *ConfigurationDistributor) encrypts(t target) bool {
+	encryption := cd.rule.Spec.Encryption
+	if encryption == nil {
+		return false
+	}
+	if len(encryption.Namespaces) == 0 {
+		return true
+	}
+	for _, namespace := range encryption.Namespaces {
+		if namespace == t.namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// encryptionKey checks if the ConfigMap contains the public key of an
+// encrypting local target.
+func (cd *ConfigurationDistributor) encryptionKey(cm *corev1.ConfigMap) bool {
+	encryption := cd.rule.Spec.Encryption
+	if encryption == nil || cm.GetName() != encryption.ConfigMapName {
+		return false
+	}
+	t, ok := cd.localTarget(cm.GetNamespace())
+	return ok && cd.encrypts(t)
+}
+
+// reencrypt applies the matching sources to the namespace again after its
+// public key has been added or changed.
+func (cd *ConfigurationDistributor) reencrypt(namespace string) {
+	cd.log.Info("public key changed", "namespace", namespace)
+	cd.applyMatchingConfigMaps(namespace)
+	cd.applyMatchingSecrets(namespace)
+}
+
+// encryptSecret encrypts the data of the Secret copy for the target. The
+// copy becomes an opaque Secret, as typed Secrets are validated by their
+// content. Each encryption is random, so the copy is annotated with the
+// keyed checksum of the plaintext and the public key. Copies with the same
+// checksum are unchanged.
+func (cd *ConfigurationDistributor) encryptSecret(out *corev1.Secret, t target) error {
+	keyPEM, err := cd.publicKeyPEM(t)
+	if err != nil {
+		return err
+	}
+	pub, err := parsePublicKey(keyPEM)
+	if err != nil {
+		return err
+	}
+	label := []byte(t.namespace + "/" + out.GetName())
+	checksum := plaintextChecksum(cd.checksumKey, keyPEM, label, out.Data)
+	data, err := encryptData(pub, label, out.Data)
+	if err != nil {
+		return err
+	}
+	out.Data = data
+	out.Type = corev1.SecretTypeOpaque
+	if out.Annotations == nil {
+		out.Annotations = map[string]string{}
+	}
+	out.Annotations[annotationEncryption] = encryptionScheme
+	out.Annotations[annotationChecksum] = checksum
+	return nil
+}
+
+// encrypted checks if the Secret copy is encrypted.
+func encrypted(scrt *corev1.Secret) bool {
+	return scrt.Annotations[annotationEncryption] != ""
+}
+
+// publicKeyPEM returns the PEM encoded public key out of the ConfigMap in
+// the namespace of the target. Local ConfigMaps are taken out of the
+// informer cache, remote ones are retrieved from their cluster.
+func (cd *ConfigurationDistributor) publicKeyPEM(t target) ([]byte, error) {
+	encryption := cd.rule.Spec.Encryption
+	var cm *corev1.ConfigMap
+	if t.cluster != "" {
+		rcm, err := t.client.CoreV1().ConfigMaps(t.namespace).Get(cd.context(), encryption.ConfigMapName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("cannot get public key configmap '%s': %v", encryption.ConfigMapName, err)
+		}
+		cm = rcm
+	} else {
+		obj, exists, err := cd.cmInformer.GetStore().GetByKey(t.namespace + "/" + encryption.ConfigMapName)
+		if err != nil || !exists {
+			return nil, fmt.Errorf("public key configmap '%s' not found", encryption.ConfigMapName)
+		}
+		cm = obj.(*corev1.ConfigMap)
+	}
+	if value, ok := cm.Data[encryption.ConfigMapKey]; ok {
+		return []byte(value), nil
+	}
+	if value, ok := cm.BinaryData[encryption.ConfigMapKey]; ok {
+		return value, nil
+	}
+	return nil, fmt.Errorf("public key configmap '%s' has no key '%s'", encryption.ConfigMapName, encryption.ConfigMapKey)
+}
+
+// parsePublicKey parses a PEM encoded RSA public key in PKIX or PKCS #1
+// format or the one of a certificate.
+func parsePublicKey(keyPEM []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("no PEM encoded public key found")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block '%s'", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse public key: %v", err)
+	}
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is no RSA key")
+	}
+	return pub, nil
+}
+
+// encryptData encrypts each value of the data with the public key. The
+// label binds the values to the namespace and name of the copy.
+func encryptData(pub *rsa.PublicKey, label []byte, data map[string][]byte) (map[string][]byte, error) {
+	encrypted := make(map[string][]byte, len(data))
+	for key, value := range data {
+		ciphertext, err := encryptValue(pub, label, value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encrypt key '%s': %v", key, err)
+		}
+		encrypted[key] = ciphertext
+	}
+	return encrypted, nil
+}
+
+// encryptValue encrypts the value with a random AES-256-GCM session key,
+// which is encrypted with RSA-OAEP and the public key. The result is the
+// length of the encrypted session key as two bytes big endian, the
+// encrypted session key, and the encrypted value. As each session key is
+// used only once the nonce is zero.
+func encryptValue(pub *rsa.PublicKey, label, value []byte) ([]byte, error) {
+	sessionKey := make([]byte, 32)
+	if _, err := rand.Read(sessionKey); err != nil {
+		return nil, err
+	}
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, sessionKey, label)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := make([]byte, 2, 2+len(encryptedKey)+len(value)+gcm.Overhead())
+	binary.BigEndian.PutUint16(ciphertext, uint16(len(encryptedKey)))
+	ciphertext = append(ciphertext, encryptedKey...)
+	nonce := make([]byte, gcm.NonceSize())
+	return gcm.Seal(ciphertext, nonce, value, nil), nil
+}
+
+// checksumKeyOrRandom returns the checksum key or, if it is empty, a
+// random one. With a random key all encrypted copies are encrypted again
+// once, as their checksums cannot be verified.
+func checksumKeyOrRandom(checksumKey []byte) ([]byte, error) {
+	if len(checksumKey) > 0 {
+		return checksumKey, nil
+	}
+	key := make([]byte, checksumKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("cannot create checksum key: %v", err)
+	}
+	return key, nil
+}
+
+// plaintextChecksum returns the hex encoded HMAC-SHA256 of the public key,
+// the label, and the data with the checksum key. Without the key, which
+// only the distributor holds, guesses of the plaintext cannot be checked
+// against the checksum.
+func plaintextChecksum(checksumKey, keyPEM, label []byte, data map[string][]byte) string {
+	h := hmac.New(sha256.New, checksumKey)
+	write := func(b []byte) {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(b)))
+		h.Write(n[:])
+		h.Write(b)
+	}
+	write(keyPEM)
+	write(label)
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		write([]byte(key))
+		write(data[key])
+	}
+	return "hmac-sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// EOF
@@ -0,0 +1,243 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"testing"
+
+	"github.com/go-logr/logr/testr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestEncryptionRoundTrip tests that encrypted copies can be decrypted
+// with the private key of the target namespace, are left unchanged as
+// long as the plaintext does not change, and are updated afterwards.
+func TestEncryptionRoundTrip(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("cannot marshal public key: %v", err)
+	}
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app",
+			Namespace: "default",
+			Labels:    map[string]string{"rule": "test"},
+		},
+		Type: corev1.SecretTypeBasicAuth,
+		Data: map[string][]byte{
+			"username": []byte("admin"),
+			"password": []byte("secret"),
+		},
+	}
+	rule := &codisv1alpha1.ConfigurationDistributionRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: codisv1alpha1.ConfigurationDistributionRuleSpec{
+			Mode:       "secret",
+			Selector:   "test",
+			Namespaces: []string{"apps"},
+			Encryption: &codisv1alpha1.Encryption{
+				ConfigMapName: "codis-key",
+				ConfigMapKey:  "public.pem",
+			},
+		},
+	}
+	client := fake.NewSimpleClientset(source, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "codis-key", Namespace: "apps"},
+		Data: map[string]string{
+			"public.pem": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		},
+	})
+	cd := newTestDistributor(t, client, rule)
+
+	cd.applySecret(source, cd.targets())
+	copy := getSecret(t, cd, "apps", "app")
+	if copy.Type != corev1.SecretTypeOpaque {
+		t.Errorf("copy has type %s, want %s", copy.Type, corev1.SecretTypeOpaque)
+	}
+	if copy.Annotations[annotationEncryption] != encryptionScheme {
+		t.Errorf("copy has encryption annotation %q", copy.Annotations[annotationEncryption])
+	}
+	checksum := copy.Annotations[annotationChecksum]
+	if checksum == "" {
+		t.Errorf("copy has no checksum annotation")
+	}
+	assertDecrypted(t, priv, "apps/app", copy.Data, source.Data)
+
+	cd.applySecret(source, cd.targets())
+	unchanged := getSecret(t, cd, "apps", "app")
+	if !equalContent(unchanged.Data, copy.Data) {
+		t.Errorf("copy has been encrypted again without changes")
+	}
+	changes, err := Plan(context.Background(), client, rule, testChecksumKey, testr.New(t))
+	if err != nil {
+		t.Fatalf("cannot plan rule: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("plan of unchanged rule has %d changes", len(changes))
+	}
+
+	source = source.DeepCopy()
+	source.Data["password"] = []byte("changed")
+	cd.applySecret(source, cd.targets())
+	updated := getSecret(t, cd, "apps", "app")
+	if updated.Annotations[annotationChecksum] == checksum {
+		t.Errorf("checksum not changed with the plaintext")
+	}
+	assertDecrypted(t, priv, "apps/app", updated.Data, source.Data)
+}
+
+// TestEncryptedProjection tests that Secrets are not projected into
+// ConfigMaps of encrypted namespaces.
+func TestEncryptedProjection(t *testing.T) {
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ca",
+			Namespace: "default",
+			Labels:    map[string]string{"rule": "test"},
+		},
+		Data: map[string][]byte{"ca.crt": []byte("certificate")},
+	}
+	client := fake.NewSimpleClientset(source)
+	cd := newTestDistributor(t, client, &codisv1alpha1.ConfigurationDistributionRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: codisv1alpha1.ConfigurationDistributionRuleSpec{
+			Mode:       "secret",
+			Selector:   "test",
+			Namespaces: []string{"apps", "tools"},
+			Projections: []codisv1alpha1.Projection{
+				{Kind: "Secret", Name: "ca", Keys: []string{"ca.crt"}},
+			},
+			Encryption: &codisv1alpha1.Encryption{
+				ConfigMapName: "codis-key",
+				ConfigMapKey:  "public.pem",
+				Namespaces:    []string{"apps"},
+			},
+		},
+	})
+
+	cd.applySecret(source, cd.targets())
+	if _, err := client.CoreV1().ConfigMaps("apps").Get(context.Background(), "ca", metav1.GetOptions{}); err == nil {
+		t.Errorf("secret projected into encrypted namespace")
+	}
+	if _, err := client.CoreV1().ConfigMaps("tools").Get(context.Background(), "ca", metav1.GetOptions{}); err != nil {
+		t.Errorf("secret not projected into unencrypted namespace: %v", err)
+	}
+}
+
+// TestPlaintextChecksum tests that the checksum of encrypted copies
+// depends on the checksum key, so it cannot be checked without it.
+func TestPlaintextChecksum(t *testing.T) {
+	data := map[string][]byte{"password": []byte("secret")}
+	checksum := plaintextChecksum(testChecksumKey, []byte("key"), []byte("apps/app"), data)
+	tests := []struct {
+		name  string
+		key   []byte
+		label string
+		data  map[string][]byte
+		equal bool
+	}{
+		{name: "same", key: testChecksumKey, label: "apps/app", data: data, equal: true},
+		{name: "other checksum key", key: []byte("other"), label: "apps/app", data: data},
+		{name: "other label", key: testChecksumKey, label: "tools/app", data: data},
+		{name: "other data", key: testChecksumKey, label: "apps/app", data: map[string][]byte{"password": []byte("guess")}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := plaintextChecksum(test.key, []byte("key"), []byte(test.label), test.data)
+			if (got == checksum) != test.equal {
+				t.Errorf("got checksum %s for %s, equal to %s is %v", got, test.name, checksum, !test.equal)
+			}
+		})
+	}
+	random, err := checksumKeyOrRandom(nil)
+	if err != nil || len(random) != checksumKeySize {
+		t.Errorf("got random checksum key of length %d and error %v", len(random), err)
+	}
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// testChecksumKey is the checksum key of the distributors in the tests.
+var testChecksumKey = []byte("test-checksum-key")
+
+// getSecret returns the Secret with the name in the namespace.
+func getSecret(t *testing.T, cd *ConfigurationDistributor, namespace, name string) *corev1.Secret {
+	t.Helper()
+	scrt, err := cd.client.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("cannot get secret %s/%s: %v", namespace, name, err)
+	}
+	return scrt
+}
+
+// assertDecrypted decrypts each encrypted value with the private key and
+// the label and compares it with the plaintext.
+func assertDecrypted(t *testing.T, priv *rsa.PrivateKey, label string, encrypted, plaintext map[string][]byte) {
+	t.Helper()
+	if len(encrypted) != len(plaintext) {
+		t.Fatalf("got %d encrypted values, want %d", len(encrypted), len(plaintext))
+	}
+	for key, want := range plaintext {
+		ciphertext := encrypted[key]
+		if string(ciphertext) == string(want) {
+			t.Errorf("value of key %s is not encrypted", key)
+			continue
+		}
+		n := int(binary.BigEndian.Uint16(ciphertext))
+		sessionKey, err := rsa.DecryptOAEP(sha256.New(), nil, priv, ciphertext[2:2+n], []byte(label))
+		if err != nil {
+			t.Errorf("cannot decrypt session key of key %s: %v", key, err)
+			continue
+		}
+		block, err := aes.NewCipher(sessionKey)
+		if err != nil {
+			t.Fatalf("cannot create cipher: %v", err)
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			t.Fatalf("cannot create GCM: %v", err)
+		}
+		value, err := gcm.Open(nil, make([]byte, gcm.NonceSize()), ciphertext[2+n:], nil)
+		if err != nil {
+			t.Errorf("cannot decrypt value of key %s: %v", key, err)
+			continue
+		}
+		if string(value) != string(want) {
+			t.Errorf("key %s has value %q, want %q", key, value, want)
+		}
+	}
+}
+
+// EOF
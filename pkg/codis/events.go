@@ -0,0 +1,115 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+// Reasons of the recorded Events.
+const (
+	reasonDistributed        = "Distributed"
+	reasonRemoved            = "Removed"
+	reasonDryRun             = "DryRun"
+	reasonDistributionFailed = "DistributionFailed"
+	reasonDriftDetected      = "DriftDetected"
+)
+
+//--------------------
+// EVENTS
+//--------------------
+
+// newRecorder creates an Event recorder writing the Events via the client.
+// The rule type has to be added to the client-go scheme for Events on rules.
+func newRecorder(client kubernetes.Interface) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: client.CoreV1().Events(""),
+	})
+	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "codis"})
+}
+
+// recordDistributed emits Events on the source and the rule about
+// a completed distribution, removal, or dry-run. Distributors without
+// a recorder, like the one of a plan, emit no Events.
+func (cd *ConfigurationDistributor) recordDistributed(source runtime.Object, kind, name string, o *outcome) {
+	if cd.recorder == nil {
+		return
+	}
+	var reason, msg string
+	switch {
+	case cd.dryRun():
+		reason = reasonDryRun
+		msg = fmt.Sprintf("Would create %d, update %d, and delete %d copies", o.created, o.updated, o.deleted)
+	case o.deleted > 0:
+		reason = reasonRemoved
+		msg = fmt.Sprintf("Removed from %d namespaces", o.deleted)
+	default:
+		reason = reasonDistributed
+		msg = fmt.Sprintf("Distributed to %d namespaces", o.created+o.updated+o.unchanged)
+	}
+	cd.recorder.Event(source, corev1.EventTypeNormal, reason, msg)
+	if cd.rule != nil {
+		cd.recorder.Eventf(cd.rule, corev1.EventTypeNormal, reason, "%s/%s: %s", kind, name, msg)
+	}
+}
+
+// recordFailed emits Warning Events on the source and the rule about
+// a failed distribution to a target.
+func (cd *ConfigurationDistributor) recordFailed(source runtime.Object, kind, name string, t target, err error) {
+	if cd.recorder == nil {
+		return
+	}
+	cd.recorder.Eventf(source, corev1.EventTypeWarning, reasonDistributionFailed,
+		"Failed to apply to namespace %s: %v", t, err)
+	if cd.rule != nil {
+		cd.recorder.Eventf(cd.rule, corev1.EventTypeWarning, reasonDistributionFailed,
+			"Failed to apply %s/%s to namespace %s: %v", kind, name, t, err)
+	}
+}
+
+// recordRefused emits Warning Events on the source and the rule if the
+// source cannot be distributed at all.
+func (cd *ConfigurationDistributor) recordRefused(source runtime.Object, kind, name string, err error) {
+	if cd.recorder == nil {
+		return
+	}
+	cd.recorder.Eventf(source, corev1.EventTypeWarning, reasonDistributionFailed,
+		"Cannot be distributed: %v", err)
+	if cd.rule != nil {
+		cd.recorder.Eventf(cd.rule, corev1.EventTypeWarning, reasonDistributionFailed,
+			"Cannot distribute %s/%s: %v", kind, name, err)
+	}
+}
+
+// recordDrift emits a Warning Event on the rule about a copy changed in
+// a target namespace.
+func (cd *ConfigurationDistributor) recordDrift(kind, name string, t target) {
+	if cd.recorder == nil || cd.rule == nil {
+		return
+	}
+	cd.recorder.Eventf(cd.rule, corev1.EventTypeWarning, reasonDriftDetected,
+		"Copy of %s/%s in namespace %s differs from its source", kind, name, t)
+}
+
+// EOF
@@ -0,0 +1,59 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"net/http"
+)
+
+//--------------------
+// HEALTH
+//--------------------
+
+// HealthzHandler returns the HTTP handler telling if the distributor
+// process is alive. It depends neither on the leadership nor on the
+// informers, so standby distributors and slow syncs do not lead to
+// restarts.
+func (cd *ConfigurationDistributor) HealthzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+// ReadyzHandler returns the HTTP handler telling if the distributor is
+// ready to work, meaning the informer caches are synced and the rule is
+// loaded. The leadership does not matter, standby distributors are ready
+// to take over.
+func (cd *ConfigurationDistributor) ReadyzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, synced := range map[string]bool{
+			"rule":      cd.ruleInformer.HasSynced(),
+			"configmap": cd.cmInformer.HasSynced(),
+			"secret":    cd.scrtInformer.HasSynced(),
+			"namespace": cd.nsInformer.HasSynced(),
+		} {
+			if !synced {
+				http.Error(w, fmt.Sprintf("%s informer not synced", name), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		_, loaded, err := cd.ruleInformer.GetStore().GetByKey(cd.namespace + "/" + cd.rulename)
+		if err != nil || !loaded {
+			http.Error(w, "rule not loaded", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+// EOF
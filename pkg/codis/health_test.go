@@ -0,0 +1,80 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+	codisfake "tideland.dev/codis/pkg/client/clientset/versioned/fake"
+	codisinformers "tideland.dev/codis/pkg/client/informers/externalversions"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestHealth tests that the liveness does not depend on the informers
+// and that the readiness only depends on the synced informers and the
+// loaded rule, not on the leadership.
+func TestHealth(t *testing.T) {
+	rule := &codisv1alpha1.ConfigurationDistributionRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: codisv1alpha1.ConfigurationDistributionRuleSpec{
+			Mode:       "configmap",
+			Selector:   "test",
+			Namespaces: []string{"apps"},
+		},
+	}
+	tests := []struct {
+		name    string
+		rules   []runtime.Object
+		started bool
+		healthz int
+		readyz  int
+	}{
+		{name: "not started", healthz: http.StatusOK, readyz: http.StatusServiceUnavailable},
+		{name: "rule not loaded", started: true, healthz: http.StatusOK, readyz: http.StatusServiceUnavailable},
+		{name: "rule loaded", rules: []runtime.Object{rule}, started: true, healthz: http.StatusOK, readyz: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cd := newTestDistributor(t, fake.NewSimpleClientset(), rule)
+			cd.ruleFactory = codisinformers.NewSharedInformerFactory(codisfake.NewSimpleClientset(test.rules...), 0)
+			cd.ruleInformer = cd.ruleFactory.Codis().V1alpha1().ConfigurationDistributionRules().Informer()
+			if test.started {
+				if err := cd.startInformers(cd.ctx); err != nil {
+					t.Fatalf("cannot start informers: %v", err)
+				}
+			}
+			for handler, want := range map[string]int{"healthz": test.healthz, "readyz": test.readyz} {
+				h := cd.HealthzHandler()
+				if handler == "readyz" {
+					h = cd.ReadyzHandler()
+				}
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+handler, nil))
+				if w.Code != want {
+					t.Errorf("got %s status %d (%q), want %d", handler, w.Code, w.Body.String(), want)
+				}
+			}
+		})
+	}
+}
+
+// EOF
@@ -0,0 +1,66 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+)
+
+//--------------------
+// LOGGING
+//--------------------
+
+// Log formats supported by NewLogger.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// NewLogger creates a structured logger writing lines in the given format
+// to the writer. Only messages up to the given verbosity are logged.
+func NewLogger(w io.Writer, format string, verbosity int) (logr.Logger, error) {
+	opts := funcr.Options{
+		LogTimestamp: true,
+		Verbosity:    verbosity,
+	}
+	switch format {
+	case LogFormatText:
+		return funcr.New(func(prefix, args string) {
+			if prefix != "" {
+				fmt.Fprintf(w, "%s: %s\n", prefix, args)
+				return
+			}
+			fmt.Fprintln(w, args)
+		}, opts), nil
+	case LogFormatJSON:
+		return funcr.NewJSON(func(obj string) {
+			fmt.Fprintln(w, obj)
+		}, opts), nil
+	}
+	return logr.Discard(), fmt.Errorf("invalid log format '%s'", format)
+}
+
+// targetLog returns the logger for the handling of a source object
+// in the given target.
+func (cd *ConfigurationDistributor) targetLog(kind, name string, t target) logr.Logger {
+	log := cd.log.WithValues("kind", kind, "name", name, "namespace", t.namespace)
+	if t.cluster != "" {
+		log = log.WithValues("cluster", t.cluster)
+	}
+	return log
+}
+
+// EOF
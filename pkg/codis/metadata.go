@@ -0,0 +1,89 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+// deniedAnnotations contains the annotations never propagated to copies.
+var deniedAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/*",
+}
+
+//--------------------
+// METADATA SANITIZING
+//--------------------
+
+// sanitizeMeta returns the metadata of a copy in the given namespace. Only
+// the name and the filtered labels and annotations are taken over, all server
+// managed fields like owner references, managed fields, timestamps, generation,
+// or finalizers are left out.
+func sanitizeMeta(in metav1.ObjectMeta, namespace string, filter codisv1alpha1.MetadataFilter) metav1.ObjectMeta {
+	denyAnnotations := append([]string{}, deniedAnnotations...)
+	denyAnnotations = append(denyAnnotations, filter.DenyAnnotations...)
+	return metav1.ObjectMeta{
+		Name:        in.Name,
+		Namespace:   namespace,
+		Labels:      filterKeys(in.Labels, filter.AllowLabels, filter.DenyLabels),
+		Annotations: filterKeys(in.Annotations, filter.AllowAnnotations, denyAnnotations),
+	}
+}
+
+// filterKeys returns the entries of the map which are allowed and not denied.
+func filterKeys(in map[string]string, allow, deny []string) map[string]string {
+	if len(in) == 0 {
+		return nil
+	}
+	out := map[string]string{}
+	for k, v := range in {
+		if len(allow) > 0 && !matchesKey(k, allow) {
+			continue
+		}
+		if matchesKey(k, deny) {
+			continue
+		}
+		out[k] = v
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// matchesKey checks if the key matches one of the patterns. Those are exact
+// keys or prefixes ending with '*'.
+func matchesKey(key string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(key, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+			continue
+		}
+		if key == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// EOF
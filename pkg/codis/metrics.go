@@ -0,0 +1,125 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+//--------------------
+// DISTRIBUTOR METRICS
+//--------------------
+
+// metrics contains the metrics about the distribution activity.
+type metrics struct {
+	registry          *prometheus.Registry
+	copiesApplied     *prometheus.CounterVec
+	copiesFailed      *prometheus.CounterVec
+	reconcileDuration *prometheus.HistogramVec
+	driftsDetected    *prometheus.CounterVec
+}
+
+// newMetrics creates the metrics of the distributor in an own registry.
+func newMetrics(cd *ConfigurationDistributor) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		copiesApplied: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "codis_copies_applied_total",
+			Help: "Number of copies applied to target namespaces.",
+		}, []string{"rule", "kind", "cluster", "namespace"}),
+		copiesFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "codis_copies_failed_total",
+			Help: "Number of copies which failed to be applied to target namespaces.",
+		}, []string{"rule", "kind", "cluster", "namespace"}),
+		reconcileDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "codis_reconcile_duration_seconds",
+			Help:    "Duration of the distribution of one source object.",
+			Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		}, []string{"rule", "kind"}),
+		driftsDetected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "codis_drifts_detected_total",
+			Help: "Number of copies changed in target namespaces differing from their sources.",
+		}, []string{"rule", "kind", "namespace"}),
+	}
+	m.registry.MustRegister(
+		m.copiesApplied,
+		m.copiesFailed,
+		m.reconcileDuration,
+		m.driftsDetected,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "codis_workqueue_depth",
+			Help: "Number of queued event handlings and cluster checks waiting to be processed.",
+		}, func() float64 {
+			return float64(cd.queue.Len())
+		}),
+	)
+	for name, informer := range map[string]func() bool{
+		"rule":      func() bool { return cd.ruleInformer != nil && cd.ruleInformer.HasSynced() },
+		"configmap": func() bool { return cd.cmInformer != nil && cd.cmInformer.HasSynced() },
+		"secret":    func() bool { return cd.scrtInformer != nil && cd.scrtInformer.HasSynced() },
+		"namespace": func() bool { return cd.nsInformer != nil && cd.nsInformer.HasSynced() },
+	} {
+		synced := informer
+		m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "codis_informer_synced",
+			Help:        "Indicates if the cache of an informer is synced.",
+			ConstLabels: prometheus.Labels{"informer": name},
+		}, func() float64 {
+			if synced() {
+				return 1
+			}
+			return 0
+		}))
+	}
+	return m
+}
+
+// MetricsHandler returns the HTTP handler exposing the metrics of
+// the distributor.
+func (cd *ConfigurationDistributor) MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(cd.metrics.registry, promhttp.HandlerOpts{})
+}
+
+// beginReconcile marks the start of the distribution of a source object
+// and returns the function to call at its end.
+func (cd *ConfigurationDistributor) beginReconcile(kind string) func() {
+	start := time.Now()
+	return func() {
+		cd.metrics.reconcileDuration.WithLabelValues(cd.rulename, kind).Observe(time.Since(start).Seconds())
+	}
+}
+
+// reportDrift counts and logs a copy in a target namespace differing
+// from its source. It is not reverted, the next distribution of the
+// source overwrites it.
+func (cd *ConfigurationDistributor) reportDrift(kind, name string, t target) {
+	cd.targetLog(kind, name, t).Info("detected drift")
+	cd.metrics.driftsDetected.WithLabelValues(cd.rulename, kind, t.namespace).Inc()
+	cd.recordDrift(kind, name, t)
+}
+
+// reportCopy counts an applied or failed copy. Unchanged copies and
+// the writes of the dry-run mode are not counted as applied.
+func (cd *ConfigurationDistributor) reportCopy(kind string, t target, action string, err error) {
+	switch {
+	case err != nil:
+		cd.metrics.copiesFailed.WithLabelValues(cd.rulename, kind, t.cluster, t.namespace).Inc()
+	case action != actionUnchanged && !cd.dryRun():
+		cd.metrics.copiesApplied.WithLabelValues(cd.rulename, kind, t.cluster, t.namespace).Inc()
+	}
+}
+
+// EOF
@@ -0,0 +1,59 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestWorkqueueDepth tests that the depth gauge reports the queued
+// handlings.
+func TestWorkqueueDepth(t *testing.T) {
+	cd := newTestDistributor(t, fake.NewSimpleClientset(), &codisv1alpha1.ConfigurationDistributionRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+	})
+	handled := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		cd.enqueue(func() { handled <- i })
+	}
+	families, err := cd.metrics.registry.Gather()
+	if err != nil {
+		t.Fatalf("cannot gather metrics: %v", err)
+	}
+	var depth float64 = -1
+	for _, family := range families {
+		if family.GetName() == "codis_workqueue_depth" {
+			depth = family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	if depth != 3 {
+		t.Errorf("got depth %v, want 3", depth)
+	}
+	go cd.work()
+	defer cd.queue.ShutDown()
+	for want := 0; want < 3; want++ {
+		if i := <-handled; i != want {
+			t.Errorf("got handling %d, want %d", i, want)
+		}
+	}
+}
+
+// EOF
@@ -0,0 +1,158 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// OVERRIDE SELECTION
+//--------------------
+
+// overridesFor returns the overrides of the rule matching the given
+// target in the order of their definition.
+func (cd *ConfigurationDistributor) overridesFor(t target) []codisv1alpha1.Override {
+	if cd.rule == nil || len(cd.rule.Spec.Overrides) == 0 {
+		return nil
+	}
+	var nsLabels map[string]string
+	if ns := cd.targetNamespace(t); ns != nil {
+		nsLabels = ns.GetLabels()
+	}
+	var overrides []codisv1alpha1.Override
+	for i, override := range cd.rule.Spec.Overrides {
+		if matchesOverride(override, cd.nsSelectors[i], t.namespace, nsLabels) {
+			overrides = append(overrides, override)
+		}
+	}
+	return overrides
+}
+
+// matchesOverride checks if the override with its namespace selector is
+// responsible for the namespace with the given name and labels. An override
+// without namespace and selector matches all target namespaces.
+func matchesOverride(override codisv1alpha1.Override, nsSelector labels.Selector, namespace string, nsLabels map[string]string) bool {
+	if override.Namespace != "" && override.Namespace != namespace {
+		return false
+	}
+	return nsSelector.Matches(labels.Set(nsLabels))
+}
+
+//--------------------
+// PATCHING
+//--------------------
+
+// patchData applies the overrides to a copy of the data. Per override the keys
+// are set first, then merged, and finally deleted.
+func patchData(data map[string]string, overrides []codisv1alpha1.Override) (map[string]string, error) {
+	if len(overrides) == 0 {
+		return data, nil
+	}
+	out := make(map[string]string, len(data))
+	for k, v := range data {
+		out[k] = v
+	}
+	for _, override := range overrides {
+		for k, v := range override.Set {
+			out[k] = v
+		}
+		for k, patch := range override.Merge {
+			merged, err := mergeValue(out[k], patch)
+			if err != nil {
+				return nil, fmt.Errorf("cannot merge key '%s': %v", k, err)
+			}
+			out[k] = merged
+		}
+		for _, k := range override.Delete {
+			delete(out, k)
+		}
+	}
+	return out, nil
+}
+
+// patchBinaryData applies the overrides to a copy of binary data like
+// the one of Secrets.
+func patchBinaryData(data map[string][]byte, overrides []codisv1alpha1.Override) (map[string][]byte, error) {
+	if len(overrides) == 0 {
+		return data, nil
+	}
+	sdata := make(map[string]string, len(data))
+	for k, v := range data {
+		sdata[k] = string(v)
+	}
+	sdata, err := patchData(sdata, overrides)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]byte, len(sdata))
+	for k, v := range sdata {
+		out[k] = []byte(v)
+	}
+	return out, nil
+}
+
+// mergeValue merges the patch into the value. Both have to be JSON or YAML
+// documents containing a map. Maps are merged recursively, all other values
+// are replaced, and null values in the patch remove the key. The result
+// keeps JSON format if the original value has been JSON.
+func mergeValue(value, patch string) (string, error) {
+	original := map[string]interface{}{}
+	if strings.TrimSpace(value) != "" {
+		if err := yaml.Unmarshal([]byte(value), &original); err != nil {
+			return "", fmt.Errorf("invalid value: %v", err)
+		}
+	}
+	changes := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(patch), &changes); err != nil {
+		return "", fmt.Errorf("invalid patch: %v", err)
+	}
+	merged := mergeMaps(original, changes)
+	var out []byte
+	var err error
+	if strings.HasPrefix(strings.TrimSpace(value), "{") {
+		out, err = json.Marshal(merged)
+	} else {
+		out, err = yaml.Marshal(merged)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// mergeMaps recursively merges the changes into the original map.
+func mergeMaps(original, changes map[string]interface{}) map[string]interface{} {
+	for k, change := range changes {
+		if change == nil {
+			delete(original, k)
+			continue
+		}
+		changeMap, changeIsMap := change.(map[string]interface{})
+		originalMap, originalIsMap := original[k].(map[string]interface{})
+		if changeIsMap && originalIsMap {
+			original[k] = mergeMaps(originalMap, changeMap)
+			continue
+		}
+		original[k] = change
+	}
+	return original
+}
+
+// EOF
@@ -0,0 +1,405 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+// diffContext is the number of unchanged lines around changes in a diff.
+const diffContext = 3
+
+//--------------------
+// CHANGE
+//--------------------
+
+// Change describes the difference between the live copy of a source in
+// a target namespace and the copy planned by a rule. Live is nil for
+// creations, Planned is nil for deletions.
+type Change struct {
+	Action    string
+	Kind      string
+	Name      string
+	Namespace string
+	Cluster   string
+	Live      runtime.Object
+	Planned   runtime.Object
+}
+
+// Path returns the path of the changed copy, e.g. "default/configmap/app".
+func (c Change) Path() string {
+	path := c.Namespace + "/" + c.Kind + "/" + c.Name
+	if c.Cluster != "" {
+		path = c.Cluster + "/" + path
+	}
+	return path
+}
+
+// Diff returns the change as unified diff of the YAML manifests in the
+// style of "kubectl diff". Secret values are masked.
+func (c Change) Diff() (string, error) {
+	live, planned := c.Live, c.Planned
+	if ls, ok := live.(*corev1.Secret); ok {
+		ps, _ := planned.(*corev1.Secret)
+		live, planned = maskSecrets(ls, ps)
+	} else if ps, ok := planned.(*corev1.Secret); ok {
+		_, planned = maskSecrets(nil, ps)
+	}
+	a, err := manifestLines(live)
+	if err != nil {
+		return "", fmt.Errorf("cannot render live %s: %v", c.Path(), err)
+	}
+	b, err := manifestLines(planned)
+	if err != nil {
+		return "", fmt.Errorf("cannot render planned %s: %v", c.Path(), err)
+	}
+	return unifiedDiff("live/"+c.Path(), "planned/"+c.Path(), a, b), nil
+}
+
+//--------------------
+// PLAN
+//--------------------
+
+// Plan computes the changes the rule would perform in the cluster of the
+// client without writing anything. It runs the distribution of all sources
+// with a recorder of the changes instead of writing them. Like in the
+// distributor failing copies and refused sources do not stop the plan,
+// their errors are returned together with the changes of the others.
+// Copies are only planned for deletion if the rule has the deletion
+// policy "delete" and a selector, they carry its label, and their source
+// is missing. Encrypted copies are only planned as unchanged with the
+// checksum key of the distributor.
+func Plan(ctx context.Context, client kubernetes.Interface, rule *codisv1alpha1.ConfigurationDistributionRule, checksumKey []byte, log logr.Logger) ([]Change, error) {
+	checksumKey, err := checksumKeyOrRandom(checksumKey)
+	if err != nil {
+		return nil, err
+	}
+	cd := &ConfigurationDistributor{
+		ctx:         ctx,
+		client:      client,
+		namespace:   rule.GetNamespace(),
+		rulename:    rule.GetName(),
+		clusters:    make(map[string]*remoteCluster),
+		reviews:     make(map[string]accessReview),
+		dryRunAll:   true,
+		checksumKey: checksumKey,
+		changes:     &changeRecorder{},
+		log:         log.WithValues("rule", rule.GetNamespace()+"/"+rule.GetName()),
+	}
+	cd.setRule(rule)
+	cd.setTracing(nil)
+	cd.metrics = newMetrics(cd)
+	factory := informers.NewSharedInformerFactory(client, 0)
+	cd.cmInformer = factory.Core().V1().ConfigMaps().Informer()
+	cd.scrtInformer = factory.Core().V1().Secrets().Informer()
+	cd.nsInformer = factory.Core().V1().Namespaces().Informer()
+	stopc := make(chan struct{})
+	defer close(stopc)
+	factory.Start(stopc)
+	for _, synced := range factory.WaitForCacheSync(stopc) {
+		if !synced {
+			return nil, errors.New("cannot sync informers")
+		}
+	}
+	cd.distributeAll()
+	if err := cd.planDeletes(); err != nil {
+		return nil, err
+	}
+	changes := cd.changes.changes
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path() < changes[j].Path()
+	})
+	return changes, utilerrors.NewAggregate(cd.changes.failures)
+}
+
+// planDeletes removes the copies in the targets whose sources are missing
+// if the deletion policy of the rule says so. They are found by the label
+// of the selector.
+func (cd *ConfigurationDistributor) planDeletes() error {
+	if cd.rule.Spec.DeletionPolicy != deletionPolicyDelete || cd.selector.Empty() {
+		return nil
+	}
+	opts := metav1.ListOptions{
+		LabelSelector: cd.selector.String(),
+	}
+	copies := cd.copies()
+	for _, t := range cd.targets() {
+		var orphans []string
+		if cd.distributes("configmap") {
+			cms, err := t.client.CoreV1().ConfigMaps(t.namespace).List(cd.ctx, opts)
+			if err != nil {
+				return fmt.Errorf("cannot list configmaps in '%s': %v", t, err)
+			}
+			for _, cm := range cms.Items {
+				orphans = append(orphans, "configmap/"+cm.GetName())
+			}
+		}
+		if cd.distributes("secret") {
+			scrts, err := t.client.CoreV1().Secrets(t.namespace).List(cd.ctx, opts)
+			if err != nil {
+				return fmt.Errorf("cannot list secrets in '%s': %v", t, err)
+			}
+			for _, scrt := range scrts.Items {
+				orphans = append(orphans, "secret/"+scrt.GetName())
+			}
+		}
+		for _, orphan := range orphans {
+			if copies[orphan] {
+				continue
+			}
+			kind, name, _ := strings.Cut(orphan, "/")
+			cd.removeCopies(cd.rule, kind, kind+"s", name, []target{t})
+		}
+	}
+	return nil
+}
+
+// copies returns the kinds and names of the copies of the sources the
+// rule distributes, e.g. "configmap/app".
+func (cd *ConfigurationDistributor) copies() map[string]bool {
+	copies := map[string]bool{}
+	add := func(kind, other string, source metav1.Object) {
+		if projection, ok := cd.projectionFor(kind, source.GetName()); ok {
+			copies[other+"/"+codisv1alpha1.ProjectedName(projection)] = true
+			return
+		}
+		copies[kind+"/"+source.GetName()] = true
+	}
+	if cd.distributes("configmap") {
+		for _, obj := range cd.cmInformer.GetStore().List() {
+			cm := obj.(*corev1.ConfigMap)
+			if cd.sourceNamespace(cm.GetNamespace()) && cd.selects("configmap", cm) && !cd.aggregated(cm) {
+				add("configmap", "secret", cm)
+			}
+		}
+		if cd.aggregates() {
+			// The aggregate removes its copies without sources itself.
+			copies["configmap/"+cd.rule.Spec.Aggregate.Name] = true
+		}
+	}
+	if cd.distributes("secret") {
+		for _, obj := range cd.scrtInformer.GetStore().List() {
+			scrt := obj.(*corev1.Secret)
+			if cd.sourceNamespace(scrt.GetNamespace()) && cd.selects("secret", scrt) {
+				add("secret", "configmap", scrt)
+			}
+		}
+	}
+	return copies
+}
+
+//--------------------
+// MANIFESTS
+//--------------------
+
+// manifestLines renders the distributed parts of a copy as YAML lines.
+// The data of Secrets is taken out of their masked string data. A nil
+// object has no lines.
+func manifestLines(obj runtime.Object) ([]string, error) {
+	var m map[string]interface{}
+	switch o := obj.(type) {
+	case *corev1.ConfigMap:
+		m = map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   manifestMeta(o.ObjectMeta),
+		}
+		if len(o.Data) > 0 {
+			m["data"] = o.Data
+		}
+		if len(o.BinaryData) > 0 {
+			m["binaryData"] = o.BinaryData
+		}
+	case *corev1.Secret:
+		m = map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   manifestMeta(o.ObjectMeta),
+			"type":       o.Type,
+		}
+		if len(o.StringData) > 0 {
+			m["data"] = o.StringData
+		}
+	default:
+		return nil, nil
+	}
+	out, err := yaml.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.SplitAfter(string(out), "\n")
+	return lines[:len(lines)-1], nil
+}
+
+// manifestMeta returns the distributed parts of the object meta.
+func manifestMeta(meta metav1.ObjectMeta) map[string]interface{} {
+	m := map[string]interface{}{
+		"name":      meta.Name,
+		"namespace": meta.Namespace,
+	}
+	if len(meta.Labels) > 0 {
+		m["labels"] = meta.Labels
+	}
+	if len(meta.Annotations) > 0 {
+		m["annotations"] = meta.Annotations
+	}
+	return m
+}
+
+// maskSecrets returns copies of the Secrets with masked values in their
+// string data like "kubectl diff" does. Equal values are masked as "***",
+// different ones as "*** (before)" and "*** (after)". Each Secret may
+// be nil.
+func maskSecrets(live, planned *corev1.Secret) (runtime.Object, runtime.Object) {
+	mask := func(in, other *corev1.Secret, changed string) runtime.Object {
+		if in == nil {
+			return nil
+		}
+		out := in.DeepCopy()
+		out.StringData = make(map[string]string, len(in.Data))
+		for key, value := range in.Data {
+			out.StringData[key] = "***"
+			if other == nil || string(other.Data[key]) != string(value) {
+				out.StringData[key] = changed
+			}
+		}
+		return out
+	}
+	return mask(live, planned, "*** (before)"), mask(planned, live, "*** (after)")
+}
+
+//--------------------
+// DIFF
+//--------------------
+
+// unifiedDiff returns the unified diff of the lines a and b.
+func unifiedDiff(from, to string, a, b []string) string {
+	ops := diffLines(a, b)
+	// Line numbers in a and b before each operation.
+	apos := make([]int, len(ops)+1)
+	bpos := make([]int, len(ops)+1)
+	for i, op := range ops {
+		apos[i+1], bpos[i+1] = apos[i], bpos[i]
+		if op[0] != '+' {
+			apos[i+1]++
+		}
+		if op[0] != '-' {
+			bpos[i+1]++
+		}
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "diff -u -N %s %s\n--- %s\n+++ %s\n", from, to, from, to)
+	for i := 0; i < len(ops); {
+		for i < len(ops) && ops[i][0] == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+		// Collect changes until the unchanged lines between them
+		// exceed the context on both sides.
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j][0] != ' ' {
+				end = j + 1
+			} else if j-end >= 2*diffContext {
+				break
+			}
+		}
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		stop := end + diffContext
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n",
+			hunkRange(apos[start], apos[stop]-apos[start]),
+			hunkRange(bpos[start], bpos[stop]-bpos[start]))
+		for _, op := range ops[start:stop] {
+			sb.WriteString(op)
+			if !strings.HasSuffix(op, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = stop
+	}
+	return sb.String()
+}
+
+// hunkRange returns the range of a hunk header.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// diffLines returns the lines of a and b prefixed with ' ', '-', or '+'
+// based on their longest common subsequence.
+func diffLines(a, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var ops []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, " "+a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, "-"+a[i])
+			i++
+		default:
+			ops = append(ops, "+"+b[j])
+			j++
+		}
+	}
+	return ops
+}
+
+// EOF
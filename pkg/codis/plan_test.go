@@ -0,0 +1,174 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr/testr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestDiffLines tests the marking of lines based on their longest common
+// subsequence.
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a    []string
+		b    []string
+		ops  []string
+	}{
+		{name: "empty"},
+		{name: "equal", a: lines("a", "b"), b: lines("a", "b"), ops: []string{" a\n", " b\n"}},
+		{name: "created", b: lines("a"), ops: []string{"+a\n"}},
+		{name: "deleted", a: lines("a"), ops: []string{"-a\n"}},
+		{name: "changed", a: lines("a", "b", "c"), b: lines("a", "x", "c"), ops: []string{" a\n", "-b\n", "+x\n", " c\n"}},
+		{name: "swapped", a: lines("a", "b"), b: lines("b", "a"), ops: []string{"-a\n", " b\n", "+a\n"}},
+		{name: "inserted", a: lines("a", "c"), b: lines("a", "b", "c"), ops: []string{" a\n", "+b\n", " c\n"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ops := diffLines(test.a, test.b)
+			if !reflect.DeepEqual(ops, test.ops) {
+				t.Errorf("got %q, want %q", ops, test.ops)
+			}
+		})
+	}
+}
+
+// TestUnifiedDiff tests the hunks of unified diffs.
+func TestUnifiedDiff(t *testing.T) {
+	const header = "diff -u -N live/x planned/x\n--- live/x\n+++ planned/x\n"
+	tests := []struct {
+		name string
+		a    []string
+		b    []string
+		diff string
+	}{
+		{name: "unchanged", a: lines("a"), b: lines("a"), diff: header},
+		{name: "created", b: lines("a", "b"), diff: header + "@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{name: "deleted", a: lines("a", "b"), diff: header + "@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{
+			name: "context",
+			a:    numbered(1, 9),
+			b:    replaced(numbered(1, 9), 5, "x"),
+			diff: header + "@@ -2,7 +2,7 @@\n l2\n l3\n l4\n-l5\n+x\n l6\n l7\n l8\n",
+		},
+		{
+			name: "merged hunks",
+			a:    numbered(1, 12),
+			b:    replaced(replaced(numbered(1, 12), 2, "x"), 8, "y"),
+			diff: header + "@@ -1,11 +1,11 @@\n l1\n-l2\n+x\n l3\n l4\n l5\n l6\n l7\n-l8\n+y\n l9\n l10\n l11\n",
+		},
+		{
+			name: "separate hunks",
+			a:    numbered(1, 20),
+			b:    replaced(replaced(numbered(1, 20), 2, "x"), 19, "y"),
+			diff: header + "@@ -1,5 +1,5 @@\n l1\n-l2\n+x\n l3\n l4\n l5\n" +
+				"@@ -16,5 +16,5 @@\n l16\n l17\n l18\n-l19\n+y\n l20\n",
+		},
+		{
+			name: "no newline",
+			a:    []string{"a"},
+			b:    []string{"b"},
+			diff: header + "@@ -1,1 +1,1 @@\n-a\n\\ No newline at end of file\n+b\n\\ No newline at end of file\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diff := unifiedDiff("live/x", "planned/x", test.a, test.b)
+			if diff != test.diff {
+				t.Errorf("got diff\n%s\nwant\n%s", diff, test.diff)
+			}
+		})
+	}
+}
+
+// TestPlanFailures tests that a source failing to render does not stop
+// the plan of the other sources, like in the distributor.
+func TestPlanFailures(t *testing.T) {
+	labels := map[string]string{"rule": "test"}
+	client := fake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "broken", Namespace: "default", Labels: labels},
+			Data:       map[string]string{"level": "{{ .Namespace"},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", Labels: labels},
+			Data:       map[string]string{"namespace": "{{ .Namespace }}"},
+		},
+	)
+	rule := &codisv1alpha1.ConfigurationDistributionRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: codisv1alpha1.ConfigurationDistributionRuleSpec{
+			Mode:       "configmap",
+			Selector:   "test",
+			Namespaces: []string{"apps", "tools"},
+			Template:   true,
+		},
+	}
+	changes, err := Plan(context.Background(), client, rule, testChecksumKey, testr.New(t))
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("got error %v, want failure of broken source", err)
+	}
+	var paths []string
+	for _, change := range changes {
+		paths = append(paths, change.Action+" "+change.Path())
+	}
+	want := []string{"create apps/configmap/app", "create tools/configmap/app"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("got changes %v, want %v", paths, want)
+	}
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// lines returns the values as lines.
+func lines(values ...string) []string {
+	var ls []string
+	for _, value := range values {
+		ls = append(ls, value+"\n")
+	}
+	return ls
+}
+
+// numbered returns the lines "l<from>" to "l<to>".
+func numbered(from, to int) []string {
+	var ls []string
+	for i := from; i <= to; i++ {
+		ls = append(ls, fmt.Sprintf("l%d\n", i))
+	}
+	return ls
+}
+
+// replaced returns the lines with the line number n replaced by the value.
+func replaced(ls []string, n int, value string) []string {
+	out := append([]string{}, ls...)
+	out[n-1] = value + "\n"
+	return out
+}
+
+// EOF
@@ -0,0 +1,157 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/trace"
+
+	corev1 "k8s.io/api/core/v1"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// PROJECTION
+//--------------------
+
+// projectionFor returns the projection of the source of the kind with
+// the name.
+func (cd *ConfigurationDistributor) projectionFor(kind, name string) (codisv1alpha1.Projection, bool) {
+	for _, projection := range cd.rule.Spec.Projections {
+		if strings.EqualFold(projection.Kind, kind) && projection.Name == name {
+			return projection, true
+		}
+	}
+	return codisv1alpha1.Projection{}, false
+}
+
+// projectionInto returns the projection creating the copy of the kind
+// with the name out of a source of the other kind.
+func (cd *ConfigurationDistributor) projectionInto(kind, name string) (codisv1alpha1.Projection, bool) {
+	source := "secret"
+	if kind == "secret" {
+		source = "configmap"
+	}
+	for _, projection := range cd.rule.Spec.Projections {
+		if strings.EqualFold(projection.Kind, source) && codisv1alpha1.ProjectedName(projection) == name {
+			return projection, true
+		}
+	}
+	return codisv1alpha1.Projection{}, false
+}
+
+// projectSecret returns the ConfigMap containing the projected keys of
+// the Secret. Values which are no valid UTF-8 become binary data.
+func projectSecret(in *corev1.Secret, projection codisv1alpha1.Projection) (*corev1.ConfigMap, error) {
+	out := &corev1.ConfigMap{
+		ObjectMeta: *in.ObjectMeta.DeepCopy(),
+	}
+	out.SetName(codisv1alpha1.ProjectedName(projection))
+	for _, key := range projection.Keys {
+		value, ok := in.Data[key]
+		if !ok {
+			return nil, fmt.Errorf("secret '%s' has no key '%s'", in.GetName(), key)
+		}
+		if utf8.Valid(value) {
+			if out.Data == nil {
+				out.Data = map[string]string{}
+			}
+			out.Data[key] = string(value)
+			continue
+		}
+		if out.BinaryData == nil {
+			out.BinaryData = map[string][]byte{}
+		}
+		out.BinaryData[key] = value
+	}
+	return out, nil
+}
+
+// projectConfigMap returns the opaque Secret containing the projected keys
+// of the ConfigMap.
+func projectConfigMap(in *corev1.ConfigMap, projection codisv1alpha1.Projection) (*corev1.Secret, error) {
+	out := &corev1.Secret{
+		ObjectMeta: *in.ObjectMeta.DeepCopy(),
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{},
+	}
+	out.SetName(codisv1alpha1.ProjectedName(projection))
+	for _, key := range projection.Keys {
+		if value, ok := in.Data[key]; ok {
+			out.Data[key] = []byte(value)
+			continue
+		}
+		value, ok := in.BinaryData[key]
+		if !ok {
+			return nil, fmt.Errorf("configmap '%s' has no key '%s'", in.GetName(), key)
+		}
+		out.Data[key] = value
+	}
+	return out, nil
+}
+
+// applySecretProjection applies the ConfigMap projected out of the Secret
+// to the given targets. Encrypted targets are refused, as the ConfigMap
+// would contain the plaintext.
+func (cd *ConfigurationDistributor) applySecretProjection(ctx context.Context, span trace.Span, in *corev1.Secret, projection codisv1alpha1.Projection, targets []target) {
+	proj, err := projectSecret(in, projection)
+	if err != nil {
+		cd.refuse(span, in, "secret", in.GetName(), err)
+		return
+	}
+	o := &outcome{}
+	for _, t := range targets {
+		tctx, tspan := cd.startTargetSpan(ctx, "configmap", proj.GetName(), t)
+		var action string
+		out, err := cd.configMapFor(proj, t)
+		if err == nil && cd.encrypts(t) {
+			err = fmt.Errorf("secret '%s' cannot be projected into encrypted namespace '%s'", in.GetName(), t)
+		}
+		if err == nil {
+			action, err = cd.writeConfigMap(tctx, t, out)
+			cd.reportTarget(t, err)
+		}
+		cd.reportApply(tspan, in, "configmap", proj.GetName(), t, action, err)
+		o.add("configmap", proj.GetName(), t, action, err)
+	}
+	cd.finishReconcile(span, in, "configmap", proj.GetName(), o)
+}
+
+// applyConfigMapProjection applies the Secret projected out of the
+// ConfigMap to the given targets.
+func (cd *ConfigurationDistributor) applyConfigMapProjection(ctx context.Context, span trace.Span, in *corev1.ConfigMap, projection codisv1alpha1.Projection, targets []target) {
+	proj, err := projectConfigMap(in, projection)
+	if err != nil {
+		cd.refuse(span, in, "configmap", in.GetName(), err)
+		return
+	}
+	o := &outcome{}
+	for _, t := range targets {
+		tctx, tspan := cd.startTargetSpan(ctx, "secret", proj.GetName(), t)
+		var action string
+		out, err := cd.secretFor(proj, t, corev1.SecretTypeOpaque)
+		if err == nil {
+			action, err = cd.writeSecret(tctx, t, out)
+			cd.reportTarget(t, err)
+		}
+		cd.reportApply(tspan, in, "secret", proj.GetName(), t, action, err)
+		o.add("secret", proj.GetName(), t, action, err)
+	}
+	cd.finishReconcile(span, in, "secret", proj.GetName(), o)
+}
+
+// EOF
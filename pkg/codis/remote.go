@@ -0,0 +1,278 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+	"tideland.dev/codis/pkg/tracing"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+// defaultKubeconfigKey is the key of the kubeconfig in a cluster Secret
+// if the rule does not name one.
+const defaultKubeconfigKey = "kubeconfig"
+
+//--------------------
+// TARGET
+//--------------------
+
+// target describes one namespace copies are written to, either in the
+// local or in a remote cluster.
+type target struct {
+	cluster   string
+	client    kubernetes.Interface
+	namespace string
+}
+
+// String implements fmt.Stringer.
+func (t target) String() string {
+	if t.cluster == "" {
+		return t.namespace
+	}
+	return t.cluster + "/" + t.namespace
+}
+
+// targets returns the local and remote targets of the rule. Remote
+// clusters which cannot be connected are left out.
+func (cd *ConfigurationDistributor) targets() []target {
+	var ts []target
+	for _, namespace := range cd.rule.Spec.Namespaces {
+		if cd.sourceNamespace(namespace) {
+			// Sources are never overwritten by copies.
+			continue
+		}
+		ts = append(ts, target{
+			client:    cd.client,
+			namespace: namespace,
+		})
+	}
+	for _, cluster := range cd.rule.Spec.Clusters {
+		client, err := cd.remoteClient(cluster)
+		if err != nil {
+			cd.log.Error(err, "cannot connect cluster", "cluster", cluster.Name)
+			cd.reportCluster(cluster.Name, err)
+			continue
+		}
+		for _, namespace := range cluster.Namespaces {
+			ts = append(ts, target{
+				cluster:   cluster.Name,
+				client:    client,
+				namespace: namespace,
+			})
+		}
+	}
+	return ts
+}
+
+//--------------------
+// REMOTE CLUSTER
+//--------------------
+
+// remoteCluster contains the client and the health of a remote cluster.
+type remoteCluster struct {
+	version string
+	client  kubernetes.Interface
+	status  codisv1alpha1.ClusterStatus
+}
+
+// kubeconfigSecret checks if the Secret contains the kubeconfig of one
+// of the remote clusters. Those are never distributed, even if they are
+// selected or referenced.
+func (cd *ConfigurationDistributor) kubeconfigSecret(scrt metav1.Object) bool {
+	if scrt.GetNamespace() != cd.namespace {
+		return false
+	}
+	for _, cluster := range cd.rule.Spec.Clusters {
+		if cluster.SecretName == scrt.GetName() {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteClient returns the client of the remote cluster. It is created out
+// of the kubeconfig Secret and renewed each time the Secret changes.
+func (cd *ConfigurationDistributor) remoteClient(cluster codisv1alpha1.Cluster) (kubernetes.Interface, error) {
+	obj, exists, err := cd.scrtInformer.GetStore().GetByKey(cd.namespace + "/" + cluster.SecretName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("kubeconfig secret '%s' not found", cluster.SecretName)
+	}
+	scrt := obj.(*corev1.Secret)
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	rc, ok := cd.clusters[cluster.Name]
+	if ok && rc.client != nil && rc.version == scrt.GetResourceVersion() {
+		return rc.client, nil
+	}
+	key := cluster.SecretKey
+	if key == "" {
+		key = defaultKubeconfigKey
+	}
+	kubeconfig, ok := scrt.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("kubeconfig secret '%s' has no key '%s'", cluster.SecretName, key)
+	}
+	if err := checkKubeconfig(kubeconfig); err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig: %v", err)
+	}
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig: %v", err)
+	}
+	if cd.traceProvider != nil {
+		tracing.WrapConfig(config, cd.traceProvider)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	status := codisv1alpha1.ClusterStatus{Name: cluster.Name}
+	if rc != nil {
+		status = rc.status
+	}
+	cd.clusters[cluster.Name] = &remoteCluster{
+		version: scrt.GetResourceVersion(),
+		client:  client,
+		status:  status,
+	}
+	return client, nil
+}
+
+// checkKubeconfig checks that the kubeconfig only contains inline
+// credentials. Exec and auth provider plugins would run commands in the
+// controller, file references would read its files, e.g. its own token.
+func checkKubeconfig(kubeconfig []byte) error {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return err
+	}
+	for name, authInfo := range config.AuthInfos {
+		switch {
+		case authInfo.Exec != nil:
+			return fmt.Errorf("user '%s' uses an exec plugin", name)
+		case authInfo.AuthProvider != nil:
+			return fmt.Errorf("user '%s' uses an auth provider", name)
+		case authInfo.TokenFile != "" || authInfo.ClientCertificate != "" || authInfo.ClientKey != "":
+			return fmt.Errorf("user '%s' references files", name)
+		}
+	}
+	for name, cluster := range config.Clusters {
+		if cluster.CertificateAuthority != "" {
+			return fmt.Errorf("cluster '%s' references files", name)
+		}
+	}
+	return nil
+}
+
+//--------------------
+// HEALTH
+//--------------------
+
+// checkClusters probes the API servers of all remote clusters of the rule.
+func (cd *ConfigurationDistributor) checkClusters() {
+	if cd.rule == nil {
+		return
+	}
+	for _, cluster := range cd.rule.Spec.Clusters {
+		client, err := cd.remoteClient(cluster)
+		if err == nil {
+			_, err = client.Discovery().ServerVersion()
+		}
+		cd.reportCluster(cluster.Name, err)
+	}
+}
+
+// reportTarget records the result of a write to the target for the
+// health of its cluster.
+func (cd *ConfigurationDistributor) reportTarget(t target, err error) {
+	if t.cluster == "" {
+		return
+	}
+	cd.reportCluster(t.cluster, err)
+}
+
+// reportCluster sets the health of the remote cluster. Errors returned by
+// the API server itself don't make a cluster unhealthy. Changes are written
+// into the status of the rule.
+func (cd *ConfigurationDistributor) reportCluster(name string, err error) {
+	status := codisv1alpha1.ClusterStatus{
+		Name:    name,
+		Healthy: true,
+	}
+	if _, ok := err.(errors.APIStatus); err != nil && !ok {
+		status.Healthy = false
+		status.Message = err.Error()
+	}
+	cd.mu.Lock()
+	rc, ok := cd.clusters[name]
+	if !ok {
+		rc = &remoteCluster{}
+		cd.clusters[name] = rc
+	}
+	changed := rc.status != status
+	rc.status = status
+	cd.mu.Unlock()
+	if changed {
+		cd.updateStatus()
+	}
+}
+
+// updateStatus writes the readiness and the health of the remote clusters,
+// the planned operations of the dry-run mode, and the conflicts of the
+// aggregate into the status of the rule. A rule is ready when all its remote clusters are healthy.
+func (cd *ConfigurationDistributor) updateStatus() {
+	if cd.rule == nil || cd.rules == nil {
+		return
+	}
+	rule := cd.rule.DeepCopyObject().(*codisv1alpha1.ConfigurationDistributionRule)
+	rule.Status.Ready = true
+	rule.Status.Clusters = nil
+	cd.mu.Lock()
+	for _, cluster := range rule.Spec.Clusters {
+		rc, ok := cd.clusters[cluster.Name]
+		if !ok {
+			rule.Status.Ready = false
+			continue
+		}
+		rule.Status.Clusters = append(rule.Status.Clusters, rc.status)
+		rule.Status.Ready = rule.Status.Ready && rc.status.Healthy
+	}
+	rule.Status.PlannedOperations = cd.planned
+	rule.Status.Conflicts = cd.conflicts
+	cd.mu.Unlock()
+	if reflect.DeepEqual(rule.Status, cd.rule.Status) {
+		return
+	}
+	updated, err := cd.rules.UpdateStatus(cd.context(), rule, metav1.UpdateOptions{})
+	if err != nil {
+		cd.log.Error(err, "cannot update status of rule")
+		return
+	}
+	cd.setRule(updated)
+}
+
+// EOF
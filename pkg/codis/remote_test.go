@@ -0,0 +1,386 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-logr/logr/testr"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestRemoteDistribution tests the distribution of a ConfigMap to the
+// namespaces of two remote clusters and the health of the clusters.
+func TestRemoteDistribution(t *testing.T) {
+	edgeA := newFakeAPIServer()
+	defer edgeA.Close()
+	edgeB := newFakeAPIServer()
+	defer edgeB.Close()
+	source := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app",
+			Namespace: "default",
+			Labels:    map[string]string{"rule": "test"},
+		},
+		Data: map[string]string{"level": "info"},
+	}
+	client := fake.NewSimpleClientset(
+		source,
+		kubeconfigSecret(t, "edge-a", edgeA.URL, nil),
+		kubeconfigSecret(t, "edge-b", edgeB.URL, nil),
+	)
+	cd := newTestDistributor(t, client, &codisv1alpha1.ConfigurationDistributionRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: codisv1alpha1.ConfigurationDistributionRuleSpec{
+			Mode:     "configmap",
+			Selector: "test",
+			Clusters: []codisv1alpha1.Cluster{
+				{Name: "edge-a", SecretName: "edge-a", Namespaces: []string{"apps"}},
+				{Name: "edge-b", SecretName: "edge-b", Namespaces: []string{"apps", "tools"}},
+			},
+		},
+	})
+
+	cd.distributeAll()
+	for _, copy := range []struct {
+		server *fakeAPIServer
+		path   string
+	}{
+		{edgeA, "/api/v1/namespaces/apps/configmaps/app"},
+		{edgeB, "/api/v1/namespaces/apps/configmaps/app"},
+		{edgeB, "/api/v1/namespaces/tools/configmaps/app"},
+	} {
+		var cm corev1.ConfigMap
+		if !copy.server.get(copy.path, &cm) {
+			t.Fatalf("missing copy %s", copy.path)
+		}
+		if cm.Data["level"] != "info" {
+			t.Errorf("copy %s has data %v", copy.path, cm.Data)
+		}
+	}
+	if edgeA.get("/api/v1/namespaces/tools/configmaps/app", &corev1.ConfigMap{}) {
+		t.Errorf("unexpected copy in namespace of other cluster")
+	}
+
+	source = source.DeepCopy()
+	source.Data["level"] = "debug"
+	cd.applyConfigMap(source, cd.targets())
+	var cm corev1.ConfigMap
+	if !edgeB.get("/api/v1/namespaces/tools/configmaps/app", &cm) || cm.Data["level"] != "debug" {
+		t.Errorf("copy not updated: %v", cm.Data)
+	}
+
+	edgeB.Close()
+	cd.checkClusters()
+	if status := cd.clusters["edge-a"].status; !status.Healthy {
+		t.Errorf("cluster edge-a is unhealthy: %s", status.Message)
+	}
+	if status := cd.clusters["edge-b"].status; status.Healthy || status.Message == "" {
+		t.Errorf("cluster edge-b is healthy after closing it")
+	}
+}
+
+// TestRemoteKubeconfigs tests that kubeconfigs running plugins or
+// reading files of the controller are rejected.
+func TestRemoteKubeconfigs(t *testing.T) {
+	tests := []struct {
+		name     string
+		authInfo *clientcmdapi.AuthInfo
+		valid    bool
+	}{
+		{
+			name:     "token",
+			authInfo: &clientcmdapi.AuthInfo{Token: "secret"},
+			valid:    true,
+		}, {
+			name: "exec",
+			authInfo: &clientcmdapi.AuthInfo{Exec: &clientcmdapi.ExecConfig{
+				APIVersion: "client.authentication.k8s.io/v1",
+				Command:    "/bin/sh",
+			}},
+		}, {
+			name: "auth provider",
+			authInfo: &clientcmdapi.AuthInfo{AuthProvider: &clientcmdapi.AuthProviderConfig{
+				Name: "oidc",
+			}},
+		}, {
+			name:     "token file",
+			authInfo: &clientcmdapi.AuthInfo{TokenFile: "/var/run/secrets/kubernetes.io/serviceaccount/token"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(kubeconfigSecret(t, "edge", "https://edge.example.com", test.authInfo))
+			cd := newTestDistributor(t, client, &codisv1alpha1.ConfigurationDistributionRule{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: codisv1alpha1.ConfigurationDistributionRuleSpec{
+					Clusters: []codisv1alpha1.Cluster{
+						{Name: "edge", SecretName: "edge", Namespaces: []string{"apps"}},
+					},
+				},
+			})
+			_, err := cd.remoteClient(cd.rule.Spec.Clusters[0])
+			if test.valid && err != nil {
+				t.Errorf("valid kubeconfig rejected: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Errorf("invalid kubeconfig accepted")
+			}
+		})
+	}
+}
+
+// TestKubeconfigSecretsNotSelected tests that the kubeconfig Secrets of
+// the remote clusters are neither selected by label nor by reference.
+func TestKubeconfigSecretsNotSelected(t *testing.T) {
+	labels := map[string]string{"rule": "test"}
+	tests := []struct {
+		name      string
+		namespace string
+		selected  bool
+	}{
+		{name: "edge", namespace: "default"},
+		{name: "edge", namespace: "team-a", selected: true},
+		{name: "app", namespace: "default", selected: true},
+	}
+	cd := newTestDistributor(t, fake.NewSimpleClientset(), &codisv1alpha1.ConfigurationDistributionRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: codisv1alpha1.ConfigurationDistributionRuleSpec{
+			Mode:             "secret",
+			Selector:         "test",
+			SourceNamespaces: []string{"default", "team-a"},
+			Sources:          []codisv1alpha1.SourceReference{{Kind: "Secret", Name: "edge"}},
+			Clusters: []codisv1alpha1.Cluster{
+				{Name: "edge", SecretName: "edge", Namespaces: []string{"apps"}},
+			},
+		},
+	})
+	for _, test := range tests {
+		t.Run(test.namespace+"/"+test.name, func(t *testing.T) {
+			scrt := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: test.name, Namespace: test.namespace, Labels: labels}}
+			if selected := cd.selects("secret", scrt); selected != test.selected {
+				t.Errorf("got selected %v, want %v", selected, test.selected)
+			}
+		})
+	}
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// newTestDistributor creates a distributor for the rule working on the
+// client with synced informers.
+func newTestDistributor(t *testing.T, client kubernetes.Interface, rule *codisv1alpha1.ConfigurationDistributionRule) *ConfigurationDistributor {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	cd := &ConfigurationDistributor{
+		ctx:         ctx,
+		client:      client,
+		namespace:   rule.GetNamespace(),
+		rulename:    rule.GetName(),
+		clusters:    make(map[string]*remoteCluster),
+		reviews:     make(map[string]accessReview),
+		queue:       workqueue.NewTyped[*handling](),
+		checksumKey: testChecksumKey,
+		recorder:    record.NewFakeRecorder(100),
+		log:         testr.New(t),
+	}
+	cd.setRule(rule)
+	cd.setTracing(nil)
+	cd.metrics = newMetrics(cd)
+	cd.factory = informers.NewSharedInformerFactory(client, 0)
+	cd.cmInformer = cd.factory.Core().V1().ConfigMaps().Informer()
+	cd.scrtInformer = cd.factory.Core().V1().Secrets().Informer()
+	cd.nsInformer = cd.factory.Core().V1().Namespaces().Informer()
+	cd.factory.Start(ctx.Done())
+	for _, synced := range cd.factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			t.Fatalf("cannot sync informers")
+		}
+	}
+	return cd
+}
+
+// kubeconfigSecret returns a Secret containing the kubeconfig for the
+// server. Without an auth info a token is used.
+func kubeconfigSecret(t *testing.T, name, server string, authInfo *clientcmdapi.AuthInfo) *corev1.Secret {
+	t.Helper()
+	if authInfo == nil {
+		authInfo = &clientcmdapi.AuthInfo{Token: "token"}
+	}
+	config := clientcmdapi.NewConfig()
+	config.Clusters[name] = &clientcmdapi.Cluster{Server: server}
+	config.AuthInfos[name] = authInfo
+	config.Contexts[name] = &clientcmdapi.Context{Cluster: name, AuthInfo: name}
+	config.CurrentContext = name
+	kubeconfig, err := clientcmd.Write(*config)
+	if err != nil {
+		t.Fatalf("cannot write kubeconfig: %v", err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Data:       map[string][]byte{defaultKubeconfigKey: kubeconfig},
+	}
+}
+
+//--------------------
+// FAKE API SERVER
+//--------------------
+
+// fakeAPIServer is a minimal Kubernetes API server keeping the written
+// namespaced objects and the received trace parents in memory.
+type fakeAPIServer struct {
+	*httptest.Server
+	mu           sync.Mutex
+	objects      map[string][]byte
+	traceparents []string
+}
+
+// newFakeAPIServer starts a new fake API server.
+func newFakeAPIServer() *fakeAPIServer {
+	s := &fakeAPIServer{
+		objects: make(map[string][]byte),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// get unmarshals the object stored with the path and tells if it exists.
+func (s *fakeAPIServer) get(path string, obj interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, ok := s.objects[path]
+	if !ok {
+		return false
+	}
+	return json.Unmarshal(body, obj) == nil
+}
+
+// serve handles the version request and the requests for namespaced
+// objects like "/api/v1/namespaces/apps/configmaps/app".
+func (s *fakeAPIServer) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == "/version" {
+		json.NewEncoder(w).Encode(map[string]string{"major": "1", "minor": "34", "gitVersion": "v1.34.0"})
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 5 || parts[0] != "api" || parts[2] != "namespaces" {
+		s.status(w, http.StatusNotFound, metav1.StatusReasonNotFound)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if traceparent := r.Header.Get("traceparent"); traceparent != "" {
+		s.traceparents = append(s.traceparents, traceparent)
+	}
+	path := r.URL.Path
+	if r.Method == http.MethodPost {
+		body, meta, err := decodeBody(r)
+		if err != nil {
+			s.status(w, http.StatusBadRequest, metav1.StatusReasonBadRequest)
+			return
+		}
+		path += "/" + meta.GetName()
+		if _, ok := s.objects[path]; ok {
+			s.status(w, http.StatusConflict, metav1.StatusReasonAlreadyExists)
+			return
+		}
+		s.objects[path] = body
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+		return
+	}
+	body, ok := s.objects[path]
+	if !ok {
+		s.status(w, http.StatusNotFound, metav1.StatusReasonNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Write(body)
+	case http.MethodPut:
+		body, _, err := decodeBody(r)
+		if err != nil {
+			s.status(w, http.StatusBadRequest, metav1.StatusReasonBadRequest)
+			return
+		}
+		s.objects[path] = body
+		w.Write(body)
+	case http.MethodDelete:
+		delete(s.objects, path)
+		s.status(w, http.StatusOK, "")
+	default:
+		s.status(w, http.StatusMethodNotAllowed, metav1.StatusReasonMethodNotAllowed)
+	}
+}
+
+// decodeBody decodes the JSON or protobuf encoded object of the request
+// and returns it as JSON together with its metadata.
+func decodeBody(r *http.Request) ([]byte, metav1.Object, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(data, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	meta, err := apimeta.Accessor(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := json.Marshal(obj)
+	return body, meta, err
+}
+
+// status writes a status response.
+func (s *fakeAPIServer) status(w http.ResponseWriter, code int, reason metav1.StatusReason) {
+	status := metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   metav1.StatusSuccess,
+		Code:     int32(code),
+		Reason:   reason,
+	}
+	if code >= http.StatusBadRequest {
+		status.Status = metav1.StatusFailure
+	}
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
+
+// EOF
@@ -0,0 +1,107 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//--------------------
+// TEMPLATE DATA
+//--------------------
+
+// templateData contains the values available when rendering the data
+// of a distributed ConfigMap for one target namespace.
+type templateData struct {
+	Cluster     string
+	Namespace   string
+	Labels      map[string]string
+	Annotations map[string]string
+	Parameters  map[string]string
+}
+
+// templateDataFor collects the template data for the given target.
+func (cd *ConfigurationDistributor) templateDataFor(t target) templateData {
+	td := templateData{
+		Cluster:     t.cluster,
+		Namespace:   t.namespace,
+		Labels:      map[string]string{},
+		Annotations: map[string]string{},
+		Parameters:  map[string]string{},
+	}
+	if cd.rule != nil {
+		for k, v := range cd.rule.Spec.Parameters {
+			td.Parameters[k] = v
+		}
+	}
+	ns := cd.targetNamespace(t)
+	if ns == nil {
+		return td
+	}
+	for k, v := range ns.GetLabels() {
+		td.Labels[k] = v
+	}
+	for k, v := range ns.GetAnnotations() {
+		td.Annotations[k] = v
+	}
+	return td
+}
+
+// targetNamespace returns the namespace of the target or nil if it is
+// unknown. Local namespaces are taken out of the namespace informer cache,
+// remote ones are retrieved from their cluster.
+func (cd *ConfigurationDistributor) targetNamespace(t target) *corev1.Namespace {
+	if t.cluster != "" {
+		ns, err := t.client.CoreV1().Namespaces().Get(cd.context(), t.namespace, metav1.GetOptions{})
+		if err != nil {
+			return nil
+		}
+		return ns
+	}
+	obj, exists, err := cd.nsInformer.GetStore().GetByKey(t.namespace)
+	if err != nil || !exists {
+		return nil
+	}
+	return obj.(*corev1.Namespace)
+}
+
+//--------------------
+// RENDERING
+//--------------------
+
+// renderData renders each value of the data map as a Go text template
+// with the given template data. Missing keys lead to an error.
+func renderData(data map[string]string, td templateData) (map[string]string, error) {
+	if data == nil {
+		return nil, nil
+	}
+	out := make(map[string]string, len(data))
+	for key, value := range data {
+		tmpl, err := template.New(key).Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse template of key '%s': %v", key, err)
+		}
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, td); err != nil {
+			return nil, fmt.Errorf("cannot render template of key '%s': %v", key, err)
+		}
+		out[key] = sb.String()
+	}
+	return out, nil
+}
+
+// EOF
@@ -0,0 +1,107 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+// tracerName is the name of the tracer of the distributor.
+const tracerName = "tideland.dev/codis"
+
+//--------------------
+// TRACING
+//--------------------
+
+// setTracing sets the tracer provider of the distributor. Without one
+// nothing is traced.
+func (cd *ConfigurationDistributor) setTracing(tp trace.TracerProvider) {
+	cd.traceProvider = tp
+	if tp == nil {
+		tp = noop.NewTracerProvider()
+	}
+	cd.tracer = tp.Tracer(tracerName)
+}
+
+// startReconcileSpan starts the root span of the reconcile of a source object.
+func (cd *ConfigurationDistributor) startReconcileSpan(kind, name string) (context.Context, trace.Span) {
+	return cd.tracer.Start(cd.context(), "reconcile "+kind, trace.WithAttributes(
+		attribute.String("codis.rule", cd.namespace+"/"+cd.rulename),
+		attribute.String("codis.kind", kind),
+		attribute.String("codis.name", name),
+	))
+}
+
+// endReconcileSpan ends the root span of a reconcile with the outcome of
+// the copies or the error refusing the whole reconcile.
+func (cd *ConfigurationDistributor) endReconcileSpan(span trace.Span, o *outcome, err error) {
+	span.SetAttributes(
+		attribute.Int("codis.applied", o.created+o.updated+o.deleted),
+		attribute.Int("codis.unchanged", o.unchanged),
+		attribute.Int("codis.failed", o.failed),
+		attribute.Bool("codis.dryRun", cd.dryRun()),
+	)
+	switch {
+	case err != nil:
+		span.SetAttributes(attribute.String("codis.outcome", "refused"))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case o.failed > 0:
+		span.SetAttributes(attribute.String("codis.outcome", "failed"))
+		span.SetStatus(codes.Error, "copies failed")
+	default:
+		span.SetAttributes(attribute.String("codis.outcome", "applied"))
+	}
+	span.End()
+}
+
+// startTargetSpan starts the child span of writing a copy into a target.
+// The returned context is passed to the API calls of the write.
+func (cd *ConfigurationDistributor) startTargetSpan(ctx context.Context, kind, name string, t target) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		attribute.String("codis.rule", cd.namespace+"/"+cd.rulename),
+		attribute.String("codis.kind", kind),
+		attribute.String("codis.name", name),
+		attribute.String("codis.namespace", t.namespace),
+	}
+	if t.cluster != "" {
+		attrs = append(attrs, attribute.String("codis.cluster", t.cluster))
+	}
+	return cd.tracer.Start(ctx, "apply "+kind, trace.WithAttributes(attrs...))
+}
+
+// endTargetSpan ends the span of writing a copy with the performed action.
+func (cd *ConfigurationDistributor) endTargetSpan(span trace.Span, action string, err error) {
+	span.SetAttributes(attribute.Bool("codis.dryRun", cd.dryRun()))
+	if action != "" {
+		span.SetAttributes(attribute.String("codis.action", action))
+	}
+	if err != nil {
+		span.SetAttributes(attribute.String("codis.outcome", "failed"))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(attribute.String("codis.outcome", "applied"))
+	}
+	span.End()
+}
+
+// EOF
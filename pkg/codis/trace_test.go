@@ -0,0 +1,127 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestTracing tests the spans of a reconcile and the propagation of the
+// trace context to the API server of a remote cluster.
+func TestTracing(t *testing.T) {
+	edge := newFakeAPIServer()
+	defer edge.Close()
+	source := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app",
+			Namespace: "default",
+			Labels:    map[string]string{"rule": "test"},
+		},
+		Data: map[string]string{"level": "info"},
+	}
+	client := fake.NewSimpleClientset(source, kubeconfigSecret(t, "edge", edge.URL, nil))
+	cd := newTestDistributor(t, client, &codisv1alpha1.ConfigurationDistributionRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: codisv1alpha1.ConfigurationDistributionRuleSpec{
+			Mode:     "configmap",
+			Selector: "test",
+			Clusters: []codisv1alpha1.Cluster{
+				{Name: "edge", SecretName: "edge", Namespaces: []string{"apps", "tools"}},
+			},
+		},
+	})
+	recorder := tracetest.NewSpanRecorder()
+	cd.setTracing(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	cd.applyConfigMap(source, cd.targets())
+
+	var reconcile sdktrace.ReadOnlySpan
+	var applies []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch {
+		case span.Name() == "reconcile configmap":
+			reconcile = span
+		case span.Name() == "apply configmap":
+			applies = append(applies, span)
+		}
+	}
+	if reconcile == nil {
+		t.Fatalf("missing reconcile span")
+	}
+	if value := spanAttribute(reconcile, "codis.outcome"); value.AsString() != "applied" {
+		t.Errorf("reconcile span has outcome %q", value.AsString())
+	}
+	if value := spanAttribute(reconcile, "codis.applied"); value.AsInt64() != 2 {
+		t.Errorf("reconcile span has %d applied copies", value.AsInt64())
+	}
+	if len(applies) != 2 {
+		t.Fatalf("got %d apply spans, want 2", len(applies))
+	}
+	namespaces := map[string]bool{}
+	for _, apply := range applies {
+		if apply.Parent().SpanID() != reconcile.SpanContext().SpanID() {
+			t.Errorf("apply span is no child of the reconcile span")
+		}
+		if value := spanAttribute(apply, "codis.cluster"); value.AsString() != "edge" {
+			t.Errorf("apply span has cluster %q", value.AsString())
+		}
+		if value := spanAttribute(apply, "codis.action"); value.AsString() != actionCreate {
+			t.Errorf("apply span has action %q", value.AsString())
+		}
+		namespaces[spanAttribute(apply, "codis.namespace").AsString()] = true
+	}
+	if !namespaces["apps"] || !namespaces["tools"] {
+		t.Errorf("apply spans have namespaces %v", namespaces)
+	}
+
+	edge.mu.Lock()
+	defer edge.mu.Unlock()
+	traceID := reconcile.SpanContext().TraceID().String()
+	if len(edge.traceparents) == 0 {
+		t.Fatalf("remote API server received no trace context")
+	}
+	for _, traceparent := range edge.traceparents {
+		if !strings.Contains(traceparent, traceID) {
+			t.Errorf("traceparent %q is not in trace %s", traceparent, traceID)
+		}
+	}
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// spanAttribute returns the value of the span attribute with the key.
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+// EOF
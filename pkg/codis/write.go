@@ -0,0 +1,308 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+// Actions performed for copies in the targets.
+const (
+	actionCreate    = "create"
+	actionUpdate    = "update"
+	actionDelete    = "delete"
+	actionRecreate  = "recreate"
+	actionUnchanged = "unchanged"
+)
+
+// Deletion policies of a rule.
+const (
+	deletionPolicyRetain = "retain"
+	deletionPolicyDelete = "delete"
+)
+
+//--------------------
+// WRITING
+//--------------------
+
+// dryRun checks if the distributor or the rule is in dry-run mode.
+func (cd *ConfigurationDistributor) dryRun() bool {
+	return cd.dryRunAll || (cd.rule != nil && cd.rule.Spec.DryRun)
+}
+
+// writeConfigMap creates or updates the copy of a ConfigMap in the target
+// and returns the performed action.
+func (cd *ConfigurationDistributor) writeConfigMap(ctx context.Context, t target, out *corev1.ConfigMap) (string, error) {
+	cmInf := t.client.CoreV1().ConfigMaps(t.namespace)
+	existing, err := cmInf.Get(ctx, out.GetName(), metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		return actionCreate, cd.write(changeOf(actionCreate, "configmap", t, nil, out), func(dryRun []string) error {
+			_, err := cmInf.Create(ctx, out, metav1.CreateOptions{DryRun: dryRun})
+			return err
+		})
+	case err != nil:
+		return "", err
+	case !changedConfigMap(existing, out):
+		return actionUnchanged, nil
+	}
+	out.SetResourceVersion(existing.GetResourceVersion())
+	return actionUpdate, cd.write(changeOf(actionUpdate, "configmap", t, existing, out), func(dryRun []string) error {
+		_, err := cmInf.Update(ctx, out, metav1.UpdateOptions{DryRun: dryRun})
+		return err
+	})
+}
+
+// writeSecret creates or updates the copy of a Secret in the target and
+// returns the performed action. Existing copies which cannot be updated
+// due to changed types or immutability are recreated. In dry-run mode
+// only the deletion is sent, as the creation would fail with the still
+// existing copy.
+func (cd *ConfigurationDistributor) writeSecret(ctx context.Context, t target, out *corev1.Secret) (string, error) {
+	scrtInf := t.client.CoreV1().Secrets(t.namespace)
+	existing, err := scrtInf.Get(ctx, out.GetName(), metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		return actionCreate, cd.write(changeOf(actionCreate, "secret", t, nil, out), func(dryRun []string) error {
+			_, err := scrtInf.Create(ctx, out, metav1.CreateOptions{DryRun: dryRun})
+			return err
+		})
+	case err != nil:
+		return "", err
+	case !changedSecret(existing, out):
+		return actionUnchanged, nil
+	}
+	if !recreatesSecret(existing, out) {
+		out.SetResourceVersion(existing.GetResourceVersion())
+		return actionUpdate, cd.write(changeOf(actionUpdate, "secret", t, existing, out), func(dryRun []string) error {
+			_, err := scrtInf.Update(ctx, out, metav1.UpdateOptions{DryRun: dryRun})
+			return err
+		})
+	}
+	err = cd.write(changeOf(actionRecreate, "secret", t, existing, out), func(dryRun []string) error {
+		return scrtInf.Delete(ctx, out.GetName(), metav1.DeleteOptions{DryRun: dryRun})
+	})
+	if err != nil || cd.dryRun() {
+		return actionRecreate, err
+	}
+	return actionRecreate, cd.write(changeOf(actionCreate, "secret", t, nil, out), func(dryRun []string) error {
+		_, err := scrtInf.Create(ctx, out, metav1.CreateOptions{DryRun: dryRun})
+		return err
+	})
+}
+
+// deleteCopy deletes the copy of a source in the target and returns the
+// performed action. Missing copies are unchanged.
+func (cd *ConfigurationDistributor) deleteCopy(ctx context.Context, t target, resource, name string) (string, error) {
+	var live runtime.Object
+	var err error
+	switch resource {
+	case "configmaps":
+		live, err = t.client.CoreV1().ConfigMaps(t.namespace).Get(ctx, name, metav1.GetOptions{})
+	default:
+		live, err = t.client.CoreV1().Secrets(t.namespace).Get(ctx, name, metav1.GetOptions{})
+	}
+	switch {
+	case errors.IsNotFound(err):
+		return actionUnchanged, nil
+	case err != nil:
+		return "", err
+	}
+	change := changeOf(actionDelete, strings.TrimSuffix(resource, "s"), t, live, nil)
+	return actionDelete, cd.write(change, func(dryRun []string) error {
+		opts := metav1.DeleteOptions{DryRun: dryRun}
+		if resource == "configmaps" {
+			return t.client.CoreV1().ConfigMaps(t.namespace).Delete(ctx, name, opts)
+		}
+		return t.client.CoreV1().Secrets(t.namespace).Delete(ctx, name, opts)
+	})
+}
+
+// write performs the change of a copy in the target. In dry-run mode the
+// request is sent with "DryRun: All", so the API server validates it
+// without persisting anything. With a change recorder the change is only
+// recorded.
+func (cd *ConfigurationDistributor) write(change Change, persist func(dryRun []string) error) error {
+	switch {
+	case cd.changes != nil:
+		cd.changes.record(change)
+		return nil
+	case cd.dryRun():
+		return persist([]string{metav1.DryRunAll})
+	default:
+		return persist(nil)
+	}
+}
+
+// changedConfigMap checks if the existing copy of a ConfigMap differs
+// from the wanted one.
+func changedConfigMap(existing, out *corev1.ConfigMap) bool {
+	return !equalMeta(existing.ObjectMeta, out.ObjectMeta) ||
+		!equalContent(existing.Data, out.Data) ||
+		!equalContent(existing.BinaryData, out.BinaryData) ||
+		!equalImmutable(existing.Immutable, out.Immutable)
+}
+
+// changedSecret checks if the existing copy of a Secret differs from
+// the wanted one. The data of encrypted copies is compared by the
+// checksum annotation of their plaintext.
+func changedSecret(existing, out *corev1.Secret) bool {
+	return !equalMeta(existing.ObjectMeta, out.ObjectMeta) ||
+		(!encrypted(out) && !equalContent(existing.Data, out.Data)) ||
+		existing.Type != out.Type ||
+		!equalImmutable(existing.Immutable, out.Immutable)
+}
+
+// recreatesSecret checks if the existing copy of a Secret has to be
+// recreated, as its type or its immutable data cannot be updated.
+func recreatesSecret(existing, out *corev1.Secret) bool {
+	return existing.Type != out.Type || (existing.Immutable != nil && *existing.Immutable)
+}
+
+// equalImmutable compares the immutability of two objects, unset means
+// mutable.
+func equalImmutable(a, b *bool) bool {
+	return (a != nil && *a) == (b != nil && *b)
+}
+
+// equalMeta compares the propagated labels and annotations of two objects.
+func equalMeta(a, b metav1.ObjectMeta) bool {
+	return equalContent(a.Labels, b.Labels) && equalContent(a.Annotations, b.Annotations)
+}
+
+// equalContent compares two data maps, nil and empty maps are equal.
+func equalContent(a, b interface{}) bool {
+	if reflect.ValueOf(a).Len() == 0 && reflect.ValueOf(b).Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+//--------------------
+// CHANGE RECORDER
+//--------------------
+
+// changeRecorder records the changes of a distributor instead of writing
+// them, together with the errors of failed copies and refused sources.
+type changeRecorder struct {
+	changes  []Change
+	failures []error
+}
+
+// changeOf returns the change of the copy of the kind in the target. The
+// name is taken from the planned or, for deletions, the live copy.
+func changeOf(action, kind string, t target, live, planned runtime.Object) Change {
+	obj := planned
+	if obj == nil {
+		obj = live
+	}
+	return Change{
+		Action:    action,
+		Kind:      kind,
+		Name:      obj.(metav1.Object).GetName(),
+		Namespace: t.namespace,
+		Cluster:   t.cluster,
+		Live:      live,
+		Planned:   planned,
+	}
+}
+
+// record records the change.
+func (cr *changeRecorder) record(change Change) {
+	cr.changes = append(cr.changes, change)
+}
+
+// fail records the error of a failed copy or a refused source.
+func (cr *changeRecorder) fail(err error) {
+	cr.failures = append(cr.failures, err)
+}
+
+//--------------------
+// OUTCOME
+//--------------------
+
+// outcome collects the actions performed for the copies of one source.
+type outcome struct {
+	created    int
+	updated    int
+	deleted    int
+	unchanged  int
+	failed     int
+	operations []codisv1alpha1.PlannedOperation
+}
+
+// add adds the action performed for the copy in the target.
+func (o *outcome) add(kind, name string, t target, action string, err error) {
+	if err != nil {
+		o.failed++
+		return
+	}
+	switch action {
+	case actionCreate:
+		o.created++
+	case actionUpdate, actionRecreate:
+		o.updated++
+	case actionDelete:
+		o.deleted++
+	default:
+		o.unchanged++
+		return
+	}
+	o.operations = append(o.operations, codisv1alpha1.PlannedOperation{
+		Action:    action,
+		Kind:      kind,
+		Name:      name,
+		Namespace: t.namespace,
+		Cluster:   t.cluster,
+	})
+}
+
+//--------------------
+// PLANNED OPERATIONS
+//--------------------
+
+// planOperations replaces the planned operations of the source with the
+// given ones and writes them into the status of the rule. Outside of
+// dry-run mode former planned operations are removed.
+func (cd *ConfigurationDistributor) planOperations(kind, name string, ops []codisv1alpha1.PlannedOperation) {
+	cd.mu.Lock()
+	var planned []codisv1alpha1.PlannedOperation
+	for _, op := range cd.planned {
+		if op.Kind != kind || op.Name != name {
+			planned = append(planned, op)
+		}
+	}
+	if cd.dryRun() {
+		planned = append(planned, ops...)
+	}
+	changed := !reflect.DeepEqual(planned, cd.planned)
+	cd.planned = planned
+	cd.mu.Unlock()
+	if changed {
+		cd.updateStatus()
+	}
+}
+
+// EOF
@@ -0,0 +1,99 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package codis // import "tideland.dev/codis/pkg/codis"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestDryRunWrites tests that all writes of a rule in dry-run mode are
+// sent as typed requests with "DryRun: All".
+func TestDryRunWrites(t *testing.T) {
+	labels := map[string]string{"rule": "test"}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", Labels: labels},
+		Data:       map[string]string{"level": "debug"},
+	}
+	scrt := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", Labels: labels},
+		Type:       corev1.SecretTypeBasicAuth,
+		Data:       map[string][]byte{"password": []byte("secret")},
+	}
+	client := fake.NewSimpleClientset(cm, scrt,
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "tools", Labels: labels},
+			Data:       map[string]string{"level": "info"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "tools", Labels: labels},
+			Type:       corev1.SecretTypeOpaque,
+			Data:       map[string][]byte{"password": []byte("secret")},
+		},
+	)
+	cd := newTestDistributor(t, client, &codisv1alpha1.ConfigurationDistributionRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: codisv1alpha1.ConfigurationDistributionRuleSpec{
+			Mode:       "both",
+			Selector:   "test",
+			Namespaces: []string{"apps", "tools"},
+			DryRun:     true,
+		},
+	})
+	client.ClearActions()
+
+	cd.applyConfigMap(cm, cd.targets())
+	cd.applySecret(scrt, cd.targets())
+	want := map[string]bool{
+		"create configmaps apps":  true,
+		"update configmaps tools": true,
+		"create secrets apps":     true,
+		"delete secrets tools":    true,
+	}
+	got := map[string]bool{}
+	for _, action := range client.Actions() {
+		var dryRun []string
+		switch action := action.(type) {
+		case k8stesting.CreateActionImpl:
+			dryRun = action.CreateOptions.DryRun
+		case k8stesting.UpdateActionImpl:
+			dryRun = action.UpdateOptions.DryRun
+		case k8stesting.DeleteActionImpl:
+			dryRun = action.DeleteOptions.DryRun
+		default:
+			continue
+		}
+		if action.GetResource().Resource == "subjectaccessreviews" {
+			continue
+		}
+		got[action.GetVerb()+" "+action.GetResource().Resource+" "+action.GetNamespace()] = true
+		if !reflect.DeepEqual(dryRun, []string{metav1.DryRunAll}) {
+			t.Errorf("%s of %s in %s sent with dry-run %v", action.GetVerb(), action.GetResource().Resource, action.GetNamespace(), dryRun)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got writes %v, want %v", got, want)
+	}
+}
+
+// EOF
@@ -0,0 +1,87 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package tracing // import "tideland.dev/codis/pkg/tracing"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestWrapConfig tests that the requests of clients created with a
+// wrapped config are traced as children of the span in their context
+// and carry its trace context to the API server.
+func TestWrapConfig(t *testing.T) {
+	traceparents := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents <- r.Header.Get("traceparent")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"kind":       "ConfigMap",
+			"apiVersion": "v1",
+			"metadata":   map[string]string{"name": "app", "namespace": "default"},
+		})
+	}))
+	defer server.Close()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	config := &rest.Config{Host: server.URL}
+	WrapConfig(config, tp)
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		t.Fatalf("cannot create client: %v", err)
+	}
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "reconcile")
+	_, err = client.CoreV1().ConfigMaps("default").Get(ctx, "app", metav1.GetOptions{})
+	span.End()
+	if err != nil {
+		t.Fatalf("cannot get ConfigMap: %v", err)
+	}
+
+	traceparent := <-traceparents
+	traceID := span.SpanContext().TraceID().String()
+	if !strings.Contains(traceparent, traceID) {
+		t.Errorf("traceparent %q is not in trace %s", traceparent, traceID)
+	}
+	var requests []sdktrace.ReadOnlySpan
+	for _, ended := range recorder.Ended() {
+		if ended.SpanKind() == trace.SpanKindClient {
+			requests = append(requests, ended)
+		}
+	}
+	if len(requests) != 1 {
+		t.Fatalf("got %d request spans, want 1", len(requests))
+	}
+	if requests[0].Parent().SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("request span is no child of the reconcile span")
+	}
+	if !strings.Contains(traceparent, requests[0].SpanContext().SpanID().String()) {
+		t.Errorf("traceparent %q does not name the request span", traceparent)
+	}
+}
+
+// EOF
@@ -0,0 +1,108 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package webhook // import "tideland.dev/codis/pkg/webhook"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+	codisv1beta1 "tideland.dev/codis/api/v1beta1"
+)
+
+//--------------------
+// CONVERTER
+//--------------------
+
+// Converter is the conversion webhook of the rules between the API
+// versions v1alpha1 and v1beta1. It understands the ConversionReviews
+// of apiextensions.k8s.io/v1 and v1beta1, which are encoded the same.
+type Converter struct{}
+
+// NewConverter creates a converter.
+func NewConverter() *Converter {
+	return &Converter{}
+}
+
+// ServeHTTP implements http.Handler.
+func (c *Converter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var cr apiextensionsv1.ConversionReview
+	if err := json.NewDecoder(r.Body).Decode(&cr); err != nil || cr.Request == nil {
+		http.Error(w, "invalid conversion review", http.StatusBadRequest)
+		return
+	}
+	resp := &apiextensionsv1.ConversionResponse{
+		UID: cr.Request.UID,
+		Result: metav1.Status{
+			Status: metav1.StatusSuccess,
+		},
+	}
+	for _, obj := range cr.Request.Objects {
+		converted, err := convert(obj.Raw, cr.Request.DesiredAPIVersion)
+		if err != nil {
+			resp.ConvertedObjects = nil
+			resp.Result = metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: err.Error(),
+			}
+			break
+		}
+		resp.ConvertedObjects = append(resp.ConvertedObjects, converted)
+	}
+	cr.Request = nil
+	cr.Response = resp
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&cr)
+}
+
+// convert converts the encoded rule into the desired API version.
+func convert(raw []byte, desiredAPIVersion string) (runtime.RawExtension, error) {
+	var tm metav1.TypeMeta
+	if err := json.Unmarshal(raw, &tm); err != nil {
+		return runtime.RawExtension{}, fmt.Errorf("cannot decode object: %v", err)
+	}
+	var converted runtime.Object
+	var err error
+	alpha := codisv1alpha1.SchemeGroupVersion.String()
+	beta := codisv1beta1.SchemeGroupVersion.String()
+	switch {
+	case tm.APIVersion == alpha && desiredAPIVersion == beta:
+		var in codisv1alpha1.ConfigurationDistributionRule
+		if err = json.Unmarshal(raw, &in); err == nil {
+			converted, err = codisv1beta1.ConvertFromV1alpha1(&in)
+		}
+	case tm.APIVersion == beta && desiredAPIVersion == alpha:
+		var in codisv1beta1.ConfigurationDistributionRule
+		if err = json.Unmarshal(raw, &in); err == nil {
+			converted, err = codisv1beta1.ConvertToV1alpha1(&in)
+		}
+	case tm.APIVersion == desiredAPIVersion:
+		return runtime.RawExtension{Raw: raw}, nil
+	default:
+		err = fmt.Errorf("unsupported versions")
+	}
+	if err != nil {
+		return runtime.RawExtension{}, fmt.Errorf("cannot convert %s %s to %s: %v", tm.Kind, tm.APIVersion, desiredAPIVersion, err)
+	}
+	return runtime.RawExtension{Object: converted}, nil
+}
+
+// EOF
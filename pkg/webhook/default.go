@@ -0,0 +1,115 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package webhook // import "tideland.dev/codis/pkg/webhook"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// DEFAULTER
+//--------------------
+
+// patchOperation is one operation of a JSON patch.
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// Defaulter is the mutating admission webhook setting the defaults of
+// rules and normalizing their namespace lists.
+type Defaulter struct{}
+
+// NewDefaulter creates a defaulter.
+func NewDefaulter() *Defaulter {
+	return &Defaulter{}
+}
+
+// ServeHTTP implements http.Handler.
+func (d *Defaulter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveReview(w, r, d.review)
+}
+
+// review returns the JSON patch setting the defaults of created and
+// updated rules.
+func (d *Defaulter) review(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return allow()
+	}
+	var rule codisv1alpha1.ConfigurationDistributionRule
+	if err := json.Unmarshal(req.Object.Raw, &rule); err != nil {
+		return deny(http.StatusBadRequest, metav1.StatusReasonBadRequest, fmt.Sprintf("cannot decode rule: %v", err))
+	}
+	var raw struct {
+		Spec *json.RawMessage `json:"spec"`
+	}
+	if err := json.Unmarshal(req.Object.Raw, &raw); err != nil {
+		return deny(http.StatusBadRequest, metav1.StatusReasonBadRequest, fmt.Sprintf("cannot decode rule: %v", err))
+	}
+	defaulted := rule.DeepCopyObject().(*codisv1alpha1.ConfigurationDistributionRule)
+	codisv1alpha1.SetDefaults(defaulted)
+	var patch []patchOperation
+	if raw.Spec == nil {
+		patch = append(patch, patchOperation{"add", "/spec", defaulted.Spec})
+	} else {
+		patch = defaultsPatch(&rule.Spec, &defaulted.Spec)
+	}
+	if len(patch) == 0 {
+		return allow()
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return deny(http.StatusInternalServerError, metav1.StatusReasonInternalError, fmt.Sprintf("cannot encode patch: %v", err))
+	}
+	patchType := admissionv1.PatchTypeJSONPatch
+	resp := allow()
+	resp.Patch = data
+	resp.PatchType = &patchType
+	return resp
+}
+
+// defaultsPatch returns the operations patching the fields changed by the
+// defaults. Adding a field replaces an existing value.
+func defaultsPatch(spec, defaulted *codisv1alpha1.ConfigurationDistributionRuleSpec) []patchOperation {
+	var patch []patchOperation
+	add := func(path string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			patch = append(patch, patchOperation{"add", path, b})
+		}
+	}
+	add("/spec/mode", spec.Mode, defaulted.Mode)
+	add("/spec/deletionPolicy", spec.DeletionPolicy, defaulted.DeletionPolicy)
+	add("/spec/selectorKey", spec.SelectorKey, defaulted.SelectorKey)
+	add("/spec/serviceAccountName", spec.ServiceAccountName, defaulted.ServiceAccountName)
+	add("/spec/sourceNamespaces", spec.SourceNamespaces, defaulted.SourceNamespaces)
+	add("/spec/namespaces", spec.Namespaces, defaulted.Namespaces)
+	for i := range spec.Clusters {
+		add(fmt.Sprintf("/spec/clusters/%d/namespaces", i), spec.Clusters[i].Namespaces, defaulted.Clusters[i].Namespaces)
+	}
+	if spec.Encryption != nil {
+		add("/spec/encryption/configMapKey", spec.Encryption.ConfigMapKey, defaulted.Encryption.ConfigMapKey)
+		add("/spec/encryption/namespaces", spec.Encryption.Namespaces, defaulted.Encryption.Namespaces)
+	}
+	return patch
+}
+
+// EOF
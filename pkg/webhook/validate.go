@@ -0,0 +1,580 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package webhook // import "tideland.dev/codis/pkg/webhook"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+	codisv1beta1 "tideland.dev/codis/api/v1beta1"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+var (
+	// modes contains the supported distribution modes.
+	modes = []string{"configmap", "secret", "both"}
+
+	// sourceKinds contains the supported kinds of referenced sources.
+	sourceKinds = []string{"ConfigMap", "Secret"}
+
+	// deletionPolicies contains the supported deletion policies.
+	deletionPolicies = []string{"retain", "delete"}
+)
+
+//--------------------
+// VALIDATOR
+//--------------------
+
+// Validator is the validating admission webhook for rules. Beside the
+// rule itself it checks the access of the requesting user to foreign
+// source namespaces, for overlaps with other rules and, in strict mode,
+// for unknown target namespaces.
+type Validator struct {
+	client kubernetes.Interface
+	rules  *codisv1alpha1.RuleIndex
+	strict bool
+}
+
+// NewValidator creates a validator using the client to look up namespaces
+// and the rule index to look up other rules.
+func NewValidator(client kubernetes.Interface, rules *codisv1alpha1.RuleIndex, strict bool) *Validator {
+	return &Validator{
+		client: client,
+		rules:  rules,
+		strict: strict,
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (v *Validator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveReview(w, r, v.review)
+}
+
+// review validates created and updated rules.
+func (v *Validator) review(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return allow()
+	}
+	var rule codisv1alpha1.ConfigurationDistributionRule
+	if err := json.Unmarshal(req.Object.Raw, &rule); err != nil {
+		return deny(http.StatusBadRequest, metav1.StatusReasonBadRequest, fmt.Sprintf("cannot decode rule: %v", err))
+	}
+	if rule.GetNamespace() == "" {
+		rule.SetNamespace(req.Namespace)
+	}
+	// Rules are defaulted before, but the defaulting webhook may
+	// not be installed.
+	codisv1alpha1.SetDefaults(&rule)
+	errs := validateRule(&rule)
+	if len(errs) == 0 {
+		denied, err := v.validateSourceAccess(ctx, req.UserInfo, &rule)
+		if err != nil {
+			return deny(http.StatusInternalServerError, metav1.StatusReasonInternalError, fmt.Sprintf("cannot check source access: %v", err))
+		}
+		errs = append(errs, denied...)
+	}
+	if len(errs) == 0 {
+		overlaps, err := v.validateOverlaps(&rule)
+		if err != nil {
+			return deny(http.StatusInternalServerError, metav1.StatusReasonInternalError, fmt.Sprintf("cannot check overlapping rules: %v", err))
+		}
+		errs = append(errs, overlaps...)
+	}
+	if len(errs) == 0 && v.strict {
+		unknown, err := v.validateNamespaces(ctx, &rule)
+		if err != nil {
+			return deny(http.StatusInternalServerError, metav1.StatusReasonInternalError, fmt.Sprintf("cannot check namespaces: %v", err))
+		}
+		errs = append(errs, unknown...)
+	}
+	if len(errs) > 0 {
+		return deny(http.StatusUnprocessableEntity, metav1.StatusReasonInvalid, errs.ToAggregate().Error())
+	}
+	return allow()
+}
+
+// validateNamespaces checks if all local target namespaces exist.
+func (v *Validator) validateNamespaces(ctx context.Context, rule *codisv1alpha1.ConfigurationDistributionRule) (field.ErrorList, error) {
+	var errs field.ErrorList
+	for i, namespace := range rule.Spec.Namespaces {
+		_, err := v.client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+		switch {
+		case errors.IsNotFound(err):
+			errs = append(errs, field.NotFound(field.NewPath("spec", "namespaces").Index(i), namespace))
+		case err != nil:
+			return nil, err
+		}
+	}
+	return errs, nil
+}
+
+// validateSourceAccess checks if the requesting user may read the sources
+// in all source namespaces other than the one of the rule. So nobody can
+// use a rule to copy Secrets out of namespaces they cannot read.
+func (v *Validator) validateSourceAccess(ctx context.Context, user authenticationv1.UserInfo, rule *codisv1alpha1.ConfigurationDistributionRule) (field.ErrorList, error) {
+	var errs field.ErrorList
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, value := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	path := field.NewPath("spec", "sourceNamespaces")
+	for i, namespace := range rule.Spec.SourceNamespaces {
+		if namespace == rule.GetNamespace() {
+			continue
+		}
+		for _, kind := range kindsOf(rule.Spec.Mode) {
+			sar := &authorizationv1.SubjectAccessReview{
+				Spec: authorizationv1.SubjectAccessReviewSpec{
+					User:   user.Username,
+					Groups: user.Groups,
+					UID:    user.UID,
+					Extra:  extra,
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Namespace: namespace,
+						Verb:      "get",
+						Resource:  kind + "s",
+					},
+				},
+			}
+			reviewed, err := v.client.AuthorizationV1().SubjectAccessReviews().Create(ctx, sar, metav1.CreateOptions{})
+			if err != nil {
+				return nil, err
+			}
+			if !reviewed.Status.Allowed {
+				errs = append(errs, field.Forbidden(path.Index(i), fmt.Sprintf("user %s may not read %ss in namespace %s", user.Username, kind, namespace)))
+			}
+		}
+	}
+	return errs, nil
+}
+
+// validateOverlaps checks if another rule writes the same objects into
+// a shared local target namespace. Only the specifications are compared:
+// both rules select sources of a shared source namespace with the same
+// selected label or without one, or they reference sources, project
+// sources, or aggregate into copies with the same names. The other rules
+// are looked up in the rule index by target namespace and selector.
+func (v *Validator) validateOverlaps(rule *codisv1alpha1.ConfigurationDistributionRule) (field.ErrorList, error) {
+	others := map[string]*codisv1alpha1.ConfigurationDistributionRule{}
+	for _, namespace := range rule.Spec.Namespaces {
+		list, err := v.rules.ByTargetNamespace(namespace)
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range list {
+			others[other.GetNamespace()+"/"+other.GetName()] = other
+		}
+	}
+	delete(others, rule.GetNamespace()+"/"+rule.GetName())
+	// Without a selected label all rules select the same sources.
+	var sameSelector map[string]bool
+	if rule.Spec.Selector != "" {
+		list, err := v.rules.BySelector(rule.Spec.SelectorKey, rule.Spec.Selector)
+		if err != nil {
+			return nil, err
+		}
+		sameSelector = map[string]bool{}
+		for _, other := range list {
+			sameSelector[other.GetNamespace()+"/"+other.GetName()] = true
+		}
+	}
+	selects, err := selectsByLabel(rule)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(others))
+	for key := range others {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var errs field.ErrorList
+	path := field.NewPath("spec", "namespaces")
+	for _, key := range keys {
+		other := others[key].DeepCopy()
+		codisv1alpha1.SetDefaults(other)
+		namespaces := intersect(rule.Spec.Namespaces, other.Spec.Namespaces)
+		otherSelects, err := selectsByLabel(other)
+		if err != nil {
+			return nil, err
+		}
+		sharedSources := len(intersect(codisv1alpha1.SourceNamespaces(rule), codisv1alpha1.SourceNamespaces(other))) > 0
+		sharedSelector := sameSelector == nil || sameSelector[key]
+		for _, kind := range intersect(kindsOf(rule.Spec.Mode), kindsOf(other.Spec.Mode)) {
+			if sharedSources && sharedSelector && copiesBySelector(rule, kind, selects) && copiesBySelector(other, kind, otherSelects) {
+				errs = append(errs, field.Forbidden(path, fmt.Sprintf("rule %s already distributes the same %s sources to namespaces %v",
+					key, kind, namespaces)))
+				continue
+			}
+			if shared := intersect(copyNames(kind, rule), copyNames(kind, other)); len(shared) > 0 {
+				errs = append(errs, field.Forbidden(path, fmt.Sprintf("rule %s already distributes the %ss %v to namespaces %v",
+					key, kind, shared, namespaces)))
+			}
+		}
+	}
+	return errs, nil
+}
+
+// copyNames returns the names of the copies of the kind written by the
+// rule as far as they are named in its specification. These are the
+// names of its referenced sources, the aggregate, and the projections
+// of sources of the other kind.
+func copyNames(kind string, rule *codisv1alpha1.ConfigurationDistributionRule) []string {
+	var names []string
+	projected := map[string]bool{}
+	for _, projection := range rule.Spec.Projections {
+		if strings.EqualFold(projection.Kind, kind) {
+			projected[projection.Name] = true
+			continue
+		}
+		names = append(names, codisv1alpha1.ProjectedName(projection))
+	}
+	if kind == "configmap" && rule.Spec.Aggregate != nil {
+		return append(names, rule.Spec.Aggregate.Name)
+	}
+	for _, ref := range rule.Spec.Sources {
+		if strings.EqualFold(ref.Kind, kind) && !projected[ref.Name] {
+			names = append(names, ref.Name)
+		}
+	}
+	return names
+}
+
+// selectsByLabel checks if the rule selects its sources by label at all.
+// Rules without a selector only take their referenced sources or, without
+// any, all sources.
+func selectsByLabel(rule *codisv1alpha1.ConfigurationDistributionRule) (bool, error) {
+	beta, err := codisv1beta1.ConvertFromV1alpha1(rule)
+	if err != nil {
+		return false, err
+	}
+	return beta.Spec.Source.Selector != nil || len(beta.Spec.Source.References) == 0, nil
+}
+
+//--------------------
+// VALIDATION
+//--------------------
+
+// validateRule checks the specification of the rule on its own.
+func validateRule(rule *codisv1alpha1.ConfigurationDistributionRule) field.ErrorList {
+	var errs field.ErrorList
+	spec := field.NewPath("spec")
+	if !contains(modes, rule.Spec.Mode) {
+		errs = append(errs, field.NotSupported(spec.Child("mode"), rule.Spec.Mode, modes))
+	}
+	for _, msg := range validation.IsQualifiedName(rule.Spec.SelectorKey) {
+		errs = append(errs, field.Invalid(spec.Child("selectorKey"), rule.Spec.SelectorKey, msg))
+	}
+	for _, msg := range validation.IsValidLabelValue(rule.Spec.Selector) {
+		errs = append(errs, field.Invalid(spec.Child("selector"), rule.Spec.Selector, msg))
+	}
+	errs = append(errs, validateSelectors(rule)...)
+	errs = append(errs, validateSources(rule)...)
+	errs = append(errs, validateProjections(rule)...)
+	errs = append(errs, validateEncryption(rule)...)
+	errs = append(errs, validateClusterSecrets(rule)...)
+	if rule.Spec.Aggregate != nil {
+		path := spec.Child("aggregate")
+		if !contains(kindsOf(rule.Spec.Mode), "configmap") {
+			errs = append(errs, field.Forbidden(path, fmt.Sprintf("ConfigMaps are not distributed in mode %s", rule.Spec.Mode)))
+		}
+		if rule.Spec.Aggregate.Name == "" {
+			errs = append(errs, field.Required(path.Child("name"), ""))
+		} else {
+			for _, msg := range validation.IsDNS1123Subdomain(rule.Spec.Aggregate.Name) {
+				errs = append(errs, field.Invalid(path.Child("name"), rule.Spec.Aggregate.Name, msg))
+			}
+		}
+	}
+	for _, msg := range validation.IsDNS1123Subdomain(rule.Spec.ServiceAccountName) {
+		errs = append(errs, field.Invalid(spec.Child("serviceAccountName"), rule.Spec.ServiceAccountName, msg))
+	}
+	errs = append(errs, validateNamespaceList(spec.Child("sourceNamespaces"), rule.Spec.SourceNamespaces, nil)...)
+	errs = append(errs, validateNamespaceList(spec.Child("namespaces"), rule.Spec.Namespaces, codisv1alpha1.SourceNamespaces(rule))...)
+	if !contains(deletionPolicies, rule.Spec.DeletionPolicy) {
+		errs = append(errs, field.NotSupported(spec.Child("deletionPolicy"), rule.Spec.DeletionPolicy, deletionPolicies))
+	}
+	for from, to := range rule.Spec.SecretTypes {
+		if from == string(corev1.SecretTypeServiceAccountToken) || to == string(corev1.SecretTypeServiceAccountToken) {
+			errs = append(errs, field.Forbidden(spec.Child("secretTypes").Key(from), "service account tokens cannot be distributed"))
+		}
+	}
+	clusters := map[string]bool{}
+	for i, cluster := range rule.Spec.Clusters {
+		path := spec.Child("clusters").Index(i)
+		switch {
+		case cluster.Name == "":
+			errs = append(errs, field.Required(path.Child("name"), ""))
+		case clusters[cluster.Name]:
+			errs = append(errs, field.Duplicate(path.Child("name"), cluster.Name))
+		}
+		clusters[cluster.Name] = true
+		if cluster.SecretName == "" {
+			errs = append(errs, field.Required(path.Child("secretName"), ""))
+		}
+		errs = append(errs, validateNamespaceList(path.Child("namespaces"), cluster.Namespaces, nil)...)
+	}
+	return errs
+}
+
+// validateSelectors checks the v1beta1 label selectors kept with the rule.
+func validateSelectors(rule *codisv1alpha1.ConfigurationDistributionRule) field.ErrorList {
+	beta, err := codisv1beta1.ConvertFromV1alpha1(rule)
+	if err != nil {
+		path := field.NewPath("metadata", "annotations").Key(codisv1beta1.AnnotationSelectors)
+		return field.ErrorList{field.Invalid(path, rule.Annotations[codisv1beta1.AnnotationSelectors], err.Error())}
+	}
+	spec := field.NewPath("spec")
+	errs := metav1validation.ValidateLabelSelector(beta.Spec.Source.Selector, metav1validation.LabelSelectorValidationOptions{}, spec.Child("selector"))
+	for i, override := range beta.Spec.Target.Overrides {
+		errs = append(errs, metav1validation.ValidateLabelSelector(override.NamespaceSelector, metav1validation.LabelSelectorValidationOptions{}, spec.Child("overrides").Index(i).Child("selector"))...)
+	}
+	return errs
+}
+
+// validateSources checks the explicitly referenced sources.
+func validateSources(rule *codisv1alpha1.ConfigurationDistributionRule) field.ErrorList {
+	var errs field.ErrorList
+	seen := map[string]bool{}
+	namespaces := codisv1alpha1.SourceNamespaces(rule)
+	for i, ref := range rule.Spec.Sources {
+		path := field.NewPath("spec", "sources").Index(i)
+		kind := strings.ToLower(ref.Kind)
+		switch {
+		case !containsFold(sourceKinds, ref.Kind):
+			errs = append(errs, field.NotSupported(path.Child("kind"), ref.Kind, sourceKinds))
+		case !contains(kindsOf(rule.Spec.Mode), kind):
+			errs = append(errs, field.Invalid(path.Child("kind"), ref.Kind, fmt.Sprintf("is not distributed in mode %s", rule.Spec.Mode)))
+		}
+		if ref.Name == "" {
+			errs = append(errs, field.Required(path.Child("name"), ""))
+		} else {
+			for _, msg := range validation.IsDNS1123Subdomain(ref.Name) {
+				errs = append(errs, field.Invalid(path.Child("name"), ref.Name, msg))
+			}
+		}
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = rule.GetNamespace()
+		}
+		if !contains(namespaces, namespace) {
+			errs = append(errs, field.Invalid(path.Child("namespace"), ref.Namespace, "must be a source namespace of the rule"))
+		}
+		key := kind + "/" + namespace + "/" + ref.Name
+		if seen[key] {
+			errs = append(errs, field.Duplicate(path, ref))
+		}
+		seen[key] = true
+	}
+	return errs
+}
+
+// validateProjections checks the projections of sources into copies of
+// the other kind.
+func validateProjections(rule *codisv1alpha1.ConfigurationDistributionRule) field.ErrorList {
+	var errs field.ErrorList
+	seen := map[string]bool{}
+	for i, projection := range rule.Spec.Projections {
+		path := field.NewPath("spec", "projections").Index(i)
+		kind := strings.ToLower(projection.Kind)
+		switch {
+		case !containsFold(sourceKinds, projection.Kind):
+			errs = append(errs, field.NotSupported(path.Child("kind"), projection.Kind, sourceKinds))
+		case !contains(kindsOf(rule.Spec.Mode), kind):
+			errs = append(errs, field.Invalid(path.Child("kind"), projection.Kind, fmt.Sprintf("is not distributed in mode %s", rule.Spec.Mode)))
+		}
+		if projection.Name == "" {
+			errs = append(errs, field.Required(path.Child("name"), ""))
+		}
+		if projection.TargetName != "" {
+			for _, msg := range validation.IsDNS1123Subdomain(projection.TargetName) {
+				errs = append(errs, field.Invalid(path.Child("targetName"), projection.TargetName, msg))
+			}
+		}
+		if len(projection.Keys) == 0 {
+			errs = append(errs, field.Required(path.Child("keys"), ""))
+		}
+		for j, key := range projection.Keys {
+			for _, msg := range validation.IsConfigMapKey(key) {
+				errs = append(errs, field.Invalid(path.Child("keys").Index(j), key, msg))
+			}
+		}
+		if seen[kind+"/"+projection.Name] {
+			errs = append(errs, field.Duplicate(path.Child("name"), projection.Name))
+		}
+		seen[kind+"/"+projection.Name] = true
+	}
+	return errs
+}
+
+// validateClusterSecrets checks that the kubeconfig Secrets of the remote
+// clusters are neither referenced nor projected, as the distributor never
+// distributes them.
+func validateClusterSecrets(rule *codisv1alpha1.ConfigurationDistributionRule) field.ErrorList {
+	var errs field.ErrorList
+	secrets := map[string]string{}
+	for _, cluster := range rule.Spec.Clusters {
+		secrets[cluster.SecretName] = cluster.Name
+	}
+	for i, ref := range rule.Spec.Sources {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = rule.GetNamespace()
+		}
+		if cluster, ok := secrets[ref.Name]; ok && strings.EqualFold(ref.Kind, "secret") && namespace == rule.GetNamespace() {
+			path := field.NewPath("spec", "sources").Index(i)
+			errs = append(errs, field.Forbidden(path, fmt.Sprintf("contains the kubeconfig of cluster %s", cluster)))
+		}
+	}
+	for i, projection := range rule.Spec.Projections {
+		if cluster, ok := secrets[projection.Name]; ok && strings.EqualFold(projection.Kind, "secret") {
+			path := field.NewPath("spec", "projections").Index(i)
+			errs = append(errs, field.Forbidden(path, fmt.Sprintf("contains the kubeconfig of cluster %s", cluster)))
+		}
+	}
+	return errs
+}
+
+// validateEncryption checks the encryption of Secret copies. Projections
+// of Secrets into encrypted namespaces are forbidden.
+func validateEncryption(rule *codisv1alpha1.ConfigurationDistributionRule) field.ErrorList {
+	encryption := rule.Spec.Encryption
+	if encryption == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	path := field.NewPath("spec", "encryption")
+	secrets := contains(kindsOf(rule.Spec.Mode), "secret")
+	for _, projection := range rule.Spec.Projections {
+		if strings.EqualFold(projection.Kind, "configmap") {
+			secrets = true
+		}
+	}
+	if !secrets {
+		errs = append(errs, field.Forbidden(path, fmt.Sprintf("Secrets are not distributed in mode %s", rule.Spec.Mode)))
+	}
+	// Projections of Secrets into ConfigMaps would bypass the encryption.
+	targets := append([]string{}, rule.Spec.Namespaces...)
+	for _, cluster := range rule.Spec.Clusters {
+		targets = append(targets, cluster.Namespaces...)
+	}
+	encrypted := targets
+	if len(encryption.Namespaces) > 0 {
+		encrypted = intersect(encryption.Namespaces, targets)
+	}
+	for i, projection := range rule.Spec.Projections {
+		if strings.EqualFold(projection.Kind, "secret") && len(encrypted) > 0 {
+			errs = append(errs, field.Forbidden(field.NewPath("spec", "projections").Index(i),
+				fmt.Sprintf("Secret %s would be projected unencrypted into the encrypted namespaces %v", projection.Name, encrypted)))
+		}
+	}
+	if encryption.ConfigMapName == "" {
+		errs = append(errs, field.Required(path.Child("configMapName"), ""))
+	} else {
+		for _, msg := range validation.IsDNS1123Subdomain(encryption.ConfigMapName) {
+			errs = append(errs, field.Invalid(path.Child("configMapName"), encryption.ConfigMapName, msg))
+		}
+	}
+	for _, msg := range validation.IsConfigMapKey(encryption.ConfigMapKey) {
+		errs = append(errs, field.Invalid(path.Child("configMapKey"), encryption.ConfigMapKey, msg))
+	}
+	return append(errs, validateNamespaceList(path.Child("namespaces"), encryption.Namespaces, nil)...)
+}
+
+// validateNamespaceList checks the names of namespaces for validity,
+// duplicates, and the source namespaces.
+func validateNamespaceList(path *field.Path, namespaces []string, sources []string) field.ErrorList {
+	var errs field.ErrorList
+	seen := map[string]bool{}
+	for i, namespace := range namespaces {
+		for _, msg := range validation.IsDNS1123Label(namespace) {
+			errs = append(errs, field.Invalid(path.Index(i), namespace, msg))
+		}
+		if contains(sources, namespace) {
+			errs = append(errs, field.Invalid(path.Index(i), namespace, "must not be a source namespace of the rule"))
+		}
+		if seen[namespace] {
+			errs = append(errs, field.Duplicate(path.Index(i), namespace))
+		}
+		seen[namespace] = true
+	}
+	return errs
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// copiesBySelector checks if the rule selecting sources by label writes
+// copies of the kind for them and not into an aggregate.
+func copiesBySelector(rule *codisv1alpha1.ConfigurationDistributionRule, kind string, selects bool) bool {
+	return selects && (kind != "configmap" || rule.Spec.Aggregate == nil)
+}
+
+// kindsOf returns the kinds of sources distributed in the mode.
+func kindsOf(mode string) []string {
+	if mode == "both" {
+		return []string{"configmap", "secret"}
+	}
+	return []string{mode}
+}
+
+// intersect returns the values contained in both lists.
+func intersect(as, bs []string) []string {
+	var is []string
+	for _, a := range as {
+		if contains(bs, a) && !contains(is, a) {
+			is = append(is, a)
+		}
+	}
+	return is
+}
+
+// containsFold checks if the value is in the list ignoring the case.
+func containsFold(vs []string, v string) bool {
+	for _, c := range vs {
+		if strings.EqualFold(c, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains checks if the value is in the list.
+func contains(vs []string, v string) bool {
+	for _, c := range vs {
+		if c == v {
+			return true
+		}
+	}
+	return false
+}
+
+// EOF
@@ -0,0 +1,173 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package webhook // import "tideland.dev/codis/pkg/webhook"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	codisv1alpha1 "tideland.dev/codis/api/v1alpha1"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestValidateEncryptedProjections tests that projections of Secrets into
+// encrypted target namespaces are rejected.
+func TestValidateEncryptedProjections(t *testing.T) {
+	tests := []struct {
+		name      string
+		kind      string
+		encrypted []string
+		clusters  []codisv1alpha1.Cluster
+		forbidden bool
+	}{
+		{name: "all namespaces encrypted", kind: "Secret", forbidden: true},
+		{name: "target encrypted", kind: "Secret", encrypted: []string{"apps"}, forbidden: true},
+		{name: "other namespace encrypted", kind: "Secret", encrypted: []string{"other"}},
+		{name: "cluster target encrypted", kind: "Secret", encrypted: []string{"edge"}, forbidden: true,
+			clusters: []codisv1alpha1.Cluster{{Name: "edge", SecretName: "edge", Namespaces: []string{"edge"}}}},
+		{name: "configmap projection", kind: "ConfigMap"},
+		{name: "kind in lower case", kind: "secret", forbidden: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule := &codisv1alpha1.ConfigurationDistributionRule{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: codisv1alpha1.ConfigurationDistributionRuleSpec{
+					Mode:       "both",
+					Selector:   "test",
+					Namespaces: []string{"apps"},
+					Clusters:   test.clusters,
+					Projections: []codisv1alpha1.Projection{
+						{Kind: test.kind, Name: "ca", Keys: []string{"ca.crt"}},
+					},
+					Encryption: &codisv1alpha1.Encryption{
+						ConfigMapName: "codis-key",
+						Namespaces:    test.encrypted,
+					},
+				},
+			}
+			codisv1alpha1.SetDefaults(rule)
+			errs := validateRule(rule)
+			if forbidden := len(errs) > 0; forbidden != test.forbidden {
+				t.Errorf("got errors %v, want forbidden %v", errs, test.forbidden)
+			}
+		})
+	}
+}
+
+// TestValidateClusterSecrets tests that references and projections of
+// the kubeconfig Secrets of remote clusters are rejected.
+func TestValidateClusterSecrets(t *testing.T) {
+	tests := []struct {
+		name        string
+		sources     []codisv1alpha1.SourceReference
+		projections []codisv1alpha1.Projection
+		forbidden   bool
+	}{
+		{name: "other secret", sources: []codisv1alpha1.SourceReference{{Kind: "Secret", Name: "app"}}},
+		{name: "referenced", sources: []codisv1alpha1.SourceReference{{Kind: "Secret", Name: "edge"}}, forbidden: true},
+		{name: "referenced in rule namespace", sources: []codisv1alpha1.SourceReference{{Kind: "Secret", Name: "edge", Namespace: "default"}}, forbidden: true},
+		{name: "referenced in other namespace", sources: []codisv1alpha1.SourceReference{{Kind: "Secret", Name: "edge", Namespace: "team-a"}}},
+		{name: "configmap with same name", sources: []codisv1alpha1.SourceReference{{Kind: "ConfigMap", Name: "edge"}}},
+		{name: "projected", projections: []codisv1alpha1.Projection{{Kind: "Secret", Name: "edge", Keys: []string{"kubeconfig"}}}, forbidden: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule := &codisv1alpha1.ConfigurationDistributionRule{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: codisv1alpha1.ConfigurationDistributionRuleSpec{
+					Mode:             "both",
+					SourceNamespaces: []string{"default", "team-a"},
+					Sources:          test.sources,
+					Projections:      test.projections,
+					Clusters: []codisv1alpha1.Cluster{
+						{Name: "edge", SecretName: "edge", Namespaces: []string{"apps"}},
+					},
+				},
+			}
+			codisv1alpha1.SetDefaults(rule)
+			errs := validateRule(rule)
+			if forbidden := len(errs) > 0; forbidden != test.forbidden {
+				t.Errorf("got errors %v, want forbidden %v", errs, test.forbidden)
+			}
+		})
+	}
+}
+
+// TestValidateOverlaps tests that rules overlapping with other rules found
+// in the rule index are rejected.
+func TestValidateOverlaps(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, codisv1alpha1.RuleIndexers())
+	for _, other := range []*codisv1alpha1.ConfigurationDistributionRule{
+		testRule("labeled", "default", "app", []string{"apps"}),
+		testRule("unlabeled", "tools", "", []string{"tools"}),
+		testRule("named", "other", "web", []string{"web"}, codisv1alpha1.SourceReference{Kind: "ConfigMap", Name: "config"}),
+	} {
+		if err := indexer.Add(other); err != nil {
+			t.Fatalf("cannot index rule: %v", err)
+		}
+	}
+	v := NewValidator(nil, codisv1alpha1.NewRuleIndex(indexer), false)
+	tests := []struct {
+		name       string
+		rule       *codisv1alpha1.ConfigurationDistributionRule
+		overlapped bool
+	}{
+		{name: "same label", rule: testRule("test", "default", "app", []string{"apps", "web"}), overlapped: true},
+		{name: "other label", rule: testRule("test", "default", "db", []string{"apps"})},
+		{name: "other target", rule: testRule("test", "default", "app", []string{"db"})},
+		{name: "other source namespace", rule: testRule("test", "backend", "app", []string{"apps"})},
+		{name: "no label", rule: testRule("test", "tools", "", []string{"tools"}), overlapped: true},
+		{name: "updated itself", rule: testRule("labeled", "default", "app", []string{"apps"})},
+		{name: "same reference", rule: testRule("test", "default", "db", []string{"web"},
+			codisv1alpha1.SourceReference{Kind: "ConfigMap", Name: "config"}), overlapped: true},
+		{name: "other reference", rule: testRule("test", "default", "db", []string{"web"},
+			codisv1alpha1.SourceReference{Kind: "ConfigMap", Name: "settings"})},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			codisv1alpha1.SetDefaults(test.rule)
+			errs, err := v.validateOverlaps(test.rule)
+			if err != nil {
+				t.Fatalf("cannot validate overlaps: %v", err)
+			}
+			if overlapped := len(errs) > 0; overlapped != test.overlapped {
+				t.Errorf("got errors %v, want overlapped %v", errs, test.overlapped)
+			}
+		})
+	}
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// testRule returns a rule in the namespace selecting the label and
+// distributing the referenced sources into the namespaces.
+func testRule(name, namespace, selector string, namespaces []string, refs ...codisv1alpha1.SourceReference) *codisv1alpha1.ConfigurationDistributionRule {
+	return &codisv1alpha1.ConfigurationDistributionRule{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: codisv1alpha1.ConfigurationDistributionRuleSpec{
+			Mode:       "configmap",
+			Selector:   selector,
+			Namespaces: namespaces,
+			Sources:    refs,
+		},
+	}
+}
+
+// EOF
@@ -0,0 +1,71 @@
+// Tideland CoDis
+//
+// Copyright (C) 2019-2020 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license
+
+package webhook // import "tideland.dev/codis/pkg/webhook"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//--------------------
+// ADMISSION REVIEW
+//--------------------
+
+// reviewFunc reviews one admission request in the context of the HTTP
+// request.
+type reviewFunc func(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse
+
+// serveReview decodes the AdmissionReview of the HTTP request, passes its
+// request to the review function, and writes the response.
+func serveReview(w http.ResponseWriter, r *http.Request, review reviewFunc) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var ar admissionv1.AdmissionReview
+	if err := json.NewDecoder(r.Body).Decode(&ar); err != nil || ar.Request == nil {
+		http.Error(w, "invalid admission review", http.StatusBadRequest)
+		return
+	}
+	resp := review(r.Context(), ar.Request)
+	resp.UID = ar.Request.UID
+	ar.Request = nil
+	ar.Response = resp
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&ar)
+}
+
+// allow returns the response admitting the request.
+func allow() *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: true,
+	}
+}
+
+// deny returns the response rejecting the request.
+func deny(code int32, reason metav1.StatusReason, message string) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    code,
+			Reason:  reason,
+			Message: message,
+		},
+	}
+}
+
+// EOF